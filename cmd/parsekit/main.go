@@ -2,30 +2,36 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
+	"github.com/rdeusser/parsekit/diagnostic"
 	"github.com/rdeusser/parsekit/lexer"
 
-	"github.com/k0kubun/pp/v3"
-
 	"github.com/rdeusser/parsekit/internal/logging"
 	"github.com/rdeusser/parsekit/lang/golang"
+	"github.com/rdeusser/parsekit/spec"
+	"github.com/rdeusser/parsekit/token"
 	"github.com/rdeusser/parsekit/version"
 )
 
 type rootOptions struct {
 	Debug    bool
 	Lang     string
+	Spec     string
 	Filename string
 }
 
 func (o *rootOptions) Init() {
 	o.Debug = false
 	o.Lang = ""
+	o.Spec = ""
 	o.Filename = ""
 }
 
@@ -49,7 +55,9 @@ func main() {
 			}
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
-			options.Filename = args[0]
+			if len(args) > 0 {
+				options.Filename = args[0]
+			}
 			return run(logger, *options, args)
 		},
 		SilenceUsage:  true,
@@ -63,9 +71,10 @@ func main() {
 
 	cmd.PersistentFlags().BoolVar(&options.Debug, "debug", options.Debug, "Run in debug mode")
 	cmd.Flags().StringVarP(&options.Lang, "lang", "l", options.Lang, "Language to lex/parse")
+	cmd.Flags().StringVar(&options.Spec, "spec", options.Spec, "Lexer spec file (YAML or JSON) describing the language to lex")
 
 	if err := cmd.Execute(); err != nil {
-		logger.Error(err.Error())
+		logger.Error("%s", err)
 	}
 }
 
@@ -77,6 +86,15 @@ func run(logger logging.Logger, options rootOptions, args []string) error {
 		l = golang.NewLexer(lexer.WithLogger(logger))
 	}
 
+	if options.Spec != "" {
+		lang, err := spec.Load(options.Spec)
+		if err != nil {
+			return err
+		}
+		l = lexer.New(lang.Config, lexer.WithLogger(logger), lexer.WithTypes(lang.Names))
+	}
+	names := l.Types()
+
 	prompt := "> "
 
 	if len(args) == 0 {
@@ -105,10 +123,10 @@ func run(logger logging.Logger, options rootOptions, args []string) error {
 
 				tokens, err := l.Lex(input)
 				if err != nil {
-					return err
+					return report(input, err)
 				}
 
-				pp.Println(tokens)
+				printTokens(os.Stdout, tokens, names)
 			}
 		}
 
@@ -121,13 +139,49 @@ func run(logger logging.Logger, options rootOptions, args []string) error {
 			return err
 		}
 
-		tokens, err := l.Lex(string(input))
+		file := token.NewFileSet().AddFile(options.Filename, -1, len(input))
+		tokens, err := l.LexFile(file, string(input))
 		if err != nil {
-			return err
+			return report(string(input), err)
 		}
 
-		pp.Println(tokens)
+		printTokens(os.Stdout, tokens, names)
 	}
 
 	return nil
 }
+
+// printTokens prints a table of tokens with their positions, type names, and literals.
+func printTokens(w io.Writer, tokens []token.Token, names *token.Registry) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, tok := range tokens {
+		fmt.Fprintf(tw, "%s\t%s\t%q\n", tok.Pos(), names.Name(tok.Type), tok.Literal)
+	}
+	_ = tw.Flush()
+}
+
+// report prints diagnostics for lexer errors to stderr, pointing at where they occurred in input.
+func report(input string, err error) error {
+	var diags []diagnostic.Diagnostic
+
+	var errs lexer.ErrorList
+	var lerr lexer.Error
+	if errors.As(err, &errs) {
+		diags = errs.Diagnostics()
+	} else if errors.As(err, &lerr) {
+		diags = append(diags, lerr.Diagnostic())
+	} else {
+		return err
+	}
+
+	var options []diagnostic.Option
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		options = append(options, diagnostic.WithColor())
+	}
+
+	for _, d := range diags {
+		_ = diagnostic.Fprint(os.Stderr, input, d, options...)
+	}
+
+	return fmt.Errorf("lexing failed with %d error(s)", len(diags))
+}
@@ -0,0 +1,175 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+const (
+	reset = "\x1b[0m"
+	bold  = "\x1b[1m"
+	red   = "\x1b[31m"
+	blue  = "\x1b[34m"
+	cyan  = "\x1b[36m"
+)
+
+// Label marks a span of source code with an optional message.
+type Label struct {
+	Start token.Position
+	End   token.Position // if invalid or not after Start, the label covers a single character
+	Msg   string
+}
+
+// Diagnostic is a message about source code. The primary label is underlined with ^~~~ and
+// secondary labels with ----.
+type Diagnostic struct {
+	Msg       string
+	Primary   Label
+	Secondary []Label
+}
+
+// Option sets options on rendering.
+type Option func(*printer)
+
+// WithColor colors the output using ANSI escape codes.
+func WithColor() Option {
+	return func(p *printer) {
+		p.color = true
+	}
+}
+
+type printer struct {
+	color bool
+}
+
+// Render renders d against the source it was reported for, e.g.:
+//
+//	error: no rule to handle character '$'
+//	 --> 1:5
+//	  |
+//	1 | foo $ bar
+//	  |     ^
+func Render(src string, d Diagnostic, options ...Option) string {
+	var sb strings.Builder
+	_ = Fprint(&sb, src, d, options...)
+	return sb.String()
+}
+
+// Fprint renders d against the source it was reported for and writes it to w.
+func Fprint(w io.Writer, src string, d Diagnostic, options ...Option) error {
+	p := &printer{}
+	for _, option := range options {
+		option(p)
+	}
+
+	type label struct {
+		Label
+		primary bool
+	}
+
+	labels := make([]label, 0, len(d.Secondary)+1)
+	labels = append(labels, label{Label: d.Primary, primary: true})
+	for _, l := range d.Secondary {
+		labels = append(labels, label{Label: l})
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Start.Pos < labels[j].Start.Pos
+	})
+
+	width := 0
+	for _, l := range labels {
+		if n := len(strconv.Itoa(l.Start.Line)); n > width {
+			width = n
+		}
+	}
+	gutter := strings.Repeat(" ", width)
+
+	var sb strings.Builder
+	sb.WriteString(p.paint(bold+red, "error") + p.paint(bold, ": "+d.Msg) + "\n")
+	sb.WriteString(fmt.Sprintf("%s%s %s\n", gutter, p.paint(blue, "-->"), d.Primary.Start))
+	sb.WriteString(p.paint(blue, gutter+" |") + "\n")
+
+	lastLine := 0
+	for _, l := range labels {
+		if !l.Start.IsValid() || l.Start.Pos > len(src) {
+			continue
+		}
+
+		lineStart, lineEnd := lineBounds(src, l.Start.Pos)
+		if l.Start.Line != lastLine {
+			num := fmt.Sprintf("%*d |", width, l.Start.Line)
+			sb.WriteString(p.paint(blue, num) + " " + src[lineStart:lineEnd] + "\n")
+			lastLine = l.Start.Line
+		}
+
+		end := lineEnd
+		if l.End.IsValid() && l.End.Pos > l.Start.Pos && l.End.Pos < lineEnd {
+			end = l.End.Pos
+		}
+		if !l.End.IsValid() || l.End.Pos <= l.Start.Pos {
+			_, size := utf8.DecodeRuneInString(src[l.Start.Pos:])
+			end = l.Start.Pos + size
+		}
+
+		n := utf8.RuneCountInString(src[l.Start.Pos:end])
+		if n < 1 {
+			n = 1
+		}
+
+		color, underline := cyan, strings.Repeat("-", n)
+		if l.primary {
+			color, underline = bold+red, "^"+strings.Repeat("~", n-1)
+		}
+		if l.Msg != "" {
+			underline += " " + l.Msg
+		}
+
+		sb.WriteString(p.paint(blue, gutter+" |") + " " + indent(src[lineStart:l.Start.Pos]) + p.paint(color, underline) + "\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (p *printer) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + reset
+}
+
+// lineBounds returns the byte offsets of the start and end of the line containing pos, not
+// including the line terminator.
+func lineBounds(src string, pos int) (int, int) {
+	start := strings.LastIndexByte(src[:pos], '\n') + 1
+	end := len(src)
+	if i := strings.IndexByte(src[pos:], '\n'); i >= 0 {
+		end = pos + i
+	}
+	if end > start && src[end-1] == '\r' {
+		end--
+	}
+	if pos > end {
+		end = pos
+	}
+	return start, end
+}
+
+// indent returns whitespace that lines up with prefix when printed, keeping tabs as tabs.
+func indent(prefix string) string {
+	var sb strings.Builder
+	for _, ch := range prefix {
+		if ch == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,75 @@
+package diagnostic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+func TestRender(t *testing.T) {
+	src := "package main\n\nfunc main() {\n\tx := \"héllo\n}\n"
+
+	tests := map[string]struct {
+		diagnostic Diagnostic
+		options    []Option
+		want       string
+	}{
+		"single character": {
+			Diagnostic{
+				Msg:     "unexpected character",
+				Primary: Label{Start: token.Position{Pos: 8, Line: 1, Column: 9}},
+			},
+			nil,
+			"error: unexpected character\n" +
+				" --> 1:9\n" +
+				"  |\n" +
+				"1 | package main\n" +
+				"  |         ^\n",
+		},
+		"span with tab and secondary label": {
+			Diagnostic{
+				Msg: "string literal not terminated",
+				Primary: Label{
+					Start: token.Position{Pos: 34, Line: 4, Column: 7},
+					End:   token.Position{Pos: 41, Line: 4, Column: 13},
+					Msg:   "missing closing quote",
+				},
+				Secondary: []Label{
+					{
+						Start: token.Position{Pos: 14, Line: 3, Column: 1},
+						End:   token.Position{Pos: 18, Line: 3, Column: 5},
+						Msg:   "in this function",
+					},
+				},
+			},
+			nil,
+			"error: string literal not terminated\n" +
+				" --> 4:7\n" +
+				"  |\n" +
+				"3 | func main() {\n" +
+				"  | ---- in this function\n" +
+				"4 | \tx := \"héllo\n" +
+				"  | \t     ^~~~~~ missing closing quote\n",
+		},
+		"color": {
+			Diagnostic{
+				Msg:     "oops",
+				Primary: Label{Start: token.Position{Pos: 0, Line: 1, Column: 1}},
+			},
+			[]Option{WithColor()},
+			"\x1b[1m\x1b[31merror\x1b[0m\x1b[1m: oops\x1b[0m\n" +
+				" \x1b[34m-->\x1b[0m 1:1\n" +
+				"\x1b[34m  |\x1b[0m\n" +
+				"\x1b[34m1 |\x1b[0m package main\n" +
+				"\x1b[34m  |\x1b[0m \x1b[1m\x1b[31m^\x1b[0m\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Render(src, tt.diagnostic, tt.options...))
+		})
+	}
+}
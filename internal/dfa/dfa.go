@@ -0,0 +1,421 @@
+package dfa
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dead is the state the DFA is in once no pattern can match anymore.
+const Dead = -1
+
+// DFA is a minimized deterministic finite automaton that recognizes a list of patterns at once.
+// Runes are grouped into classes that every pattern treats the same way, so the transition
+// table has a column per class rather than per rune.
+type DFA struct {
+	bounds  []rune // class i covers the runes in [bounds[i], bounds[i+1])
+	ascii   [utf8.RuneSelf]int32
+	nclass  int
+	start   int
+	trans   []int32 // trans[state*nclass+class] is the next state
+	accept  []int32 // lowest-numbered pattern each state accepts, or -1
+	accepts [][]int // every pattern each state accepts
+}
+
+// Ambiguity is a set of patterns that all match the same input.
+type Ambiguity struct {
+	Patterns []int
+	Example  string
+}
+
+type inst struct {
+	op      syntax.InstOp
+	out     int
+	arg     int
+	ranges  []rune // pairs of inclusive bounds, for rune instructions
+	pattern int    // for match instructions
+}
+
+// Compile compiles patterns, which use the syntax of the regexp package, into a single DFA.
+// Every match is anchored at the start of the input. Empty-width assertions other than ^ aren't
+// supported.
+func Compile(patterns []string) (*DFA, error) {
+	insts := make([]inst, 0)
+	starts := make([]int, 0, len(patterns))
+
+	for i, pattern := range patterns {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+
+		offset := len(insts)
+		starts = append(starts, offset+prog.Start)
+
+		for _, in := range prog.Inst {
+			converted := inst{op: in.Op, out: offset + int(in.Out), arg: offset + int(in.Arg), pattern: i}
+			switch in.Op {
+			case syntax.InstEmptyWidth:
+				if syntax.EmptyOp(in.Arg) != syntax.EmptyBeginText {
+					return nil, fmt.Errorf("pattern %q: empty-width assertions other than ^ aren't supported", pattern)
+				}
+			case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+				converted.ranges = ranges(&in)
+			}
+			insts = append(insts, converted)
+		}
+	}
+
+	b := &builder{insts: insts}
+	b.partition()
+	b.subsets(starts)
+
+	return b.minimize(), nil
+}
+
+// ranges returns the runes matched by a rune instruction as pairs of inclusive bounds.
+func ranges(in *syntax.Inst) []rune {
+	switch in.Op {
+	case syntax.InstRune1:
+		return []rune{in.Rune[0], in.Rune[0]}
+	case syntax.InstRuneAny:
+		return []rune{0, unicode.MaxRune}
+	case syntax.InstRuneAnyNotNL:
+		return []rune{0, '\n' - 1, '\n' + 1, unicode.MaxRune}
+	}
+
+	if len(in.Rune) != 1 {
+		return in.Rune
+	}
+
+	r := in.Rune[0]
+	rs := []rune{r, r}
+	if syntax.Flags(in.Arg)&syntax.FoldCase != 0 {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			rs = append(rs, f, f)
+		}
+	}
+	return rs
+}
+
+type builder struct {
+	insts   []inst
+	bounds  []rune
+	classes [][]int // classes matched by each rune instruction
+
+	states  [][]int // NFA instructions making up each DFA state
+	index   map[string]int
+	trans   [][]int32
+	accepts [][]int
+}
+
+// partition partitions the runes into classes that no rune instruction tells apart.
+func (b *builder) partition() {
+	set := map[rune]bool{0: true}
+	for _, in := range b.insts {
+		for i := 0; i < len(in.ranges); i += 2 {
+			set[in.ranges[i]] = true
+			if hi := in.ranges[i+1]; hi < unicode.MaxRune {
+				set[hi+1] = true
+			}
+		}
+	}
+
+	for r := range set {
+		b.bounds = append(b.bounds, r)
+	}
+	sort.Slice(b.bounds, func(i, j int) bool { return b.bounds[i] < b.bounds[j] })
+
+	b.classes = make([][]int, len(b.insts))
+	for pc, in := range b.insts {
+		for i := 0; i < len(in.ranges); i += 2 {
+			for c := classOf(b.bounds, in.ranges[i]); c <= classOf(b.bounds, in.ranges[i+1]); c++ {
+				b.classes[pc] = append(b.classes[pc], c)
+			}
+		}
+	}
+}
+
+// closure follows empty transitions from pcs and returns the rune and match instructions
+// reached.
+func (b *builder) closure(pcs []int) []int {
+	seen := make(map[int]bool)
+	result := make([]int, 0)
+
+	var visit func(pc int)
+	visit = func(pc int) {
+		if seen[pc] {
+			return
+		}
+		seen[pc] = true
+
+		in := b.insts[pc]
+		switch in.op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			visit(in.out)
+			visit(in.arg)
+		case syntax.InstCapture, syntax.InstNop, syntax.InstEmptyWidth:
+			visit(in.out)
+		case syntax.InstMatch, syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			result = append(result, pc)
+		}
+	}
+	for _, pc := range pcs {
+		visit(pc)
+	}
+
+	sort.Ints(result)
+	return result
+}
+
+func (b *builder) state(pcs []int) int {
+	var sb strings.Builder
+	for _, pc := range pcs {
+		fmt.Fprintf(&sb, "%d,", pc)
+	}
+	key := sb.String()
+
+	if id, ok := b.index[key]; ok {
+		return id
+	}
+
+	id := len(b.states)
+	b.index[key] = id
+	b.states = append(b.states, pcs)
+
+	accepts := make([]int, 0)
+	for _, pc := range pcs {
+		if in := b.insts[pc]; in.op == syntax.InstMatch {
+			accepts = append(accepts, in.pattern)
+		}
+	}
+	sort.Ints(accepts)
+	b.accepts = append(b.accepts, accepts)
+
+	return id
+}
+
+// subsets builds a DFA from the NFA using the subset construction.
+func (b *builder) subsets(starts []int) {
+	b.index = make(map[string]int)
+	b.state(b.closure(starts))
+
+	for s := 0; s < len(b.states); s++ {
+		moves := make(map[int][]int)
+		for _, pc := range b.states[s] {
+			for _, c := range b.classes[pc] {
+				moves[c] = append(moves[c], b.insts[pc].out)
+			}
+		}
+
+		row := make([]int32, len(b.bounds))
+		for c := range row {
+			row[c] = Dead
+		}
+		for c, pcs := range moves {
+			if next := b.closure(pcs); len(next) > 0 {
+				row[c] = int32(b.state(next))
+			}
+		}
+		b.trans = append(b.trans, row)
+	}
+}
+
+// minimize merges equivalent states using Moore's partition refinement and returns the final
+// DFA.
+func (b *builder) minimize() *DFA {
+	n := len(b.states)
+	dead := n // an explicit dead state makes every row complete
+	nclass := len(b.bounds)
+
+	target := func(s, c int) int {
+		if s == dead || b.trans[s][c] == Dead {
+			return dead
+		}
+		return int(b.trans[s][c])
+	}
+
+	block := make([]int, n+1)
+	count := 0
+	{
+		ids := make(map[string]int)
+		for s := 0; s <= n; s++ {
+			// The dead state accepts nothing, so states that can never accept end up merged with it.
+			key := "[]"
+			if s != dead {
+				key = fmt.Sprint(b.accepts[s])
+			}
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			block[s] = id
+		}
+		count = len(ids)
+	}
+
+	buf := make([]byte, 4*(nclass+1))
+	for {
+		ids := make(map[string]int)
+		next := make([]int, n+1)
+		for s := 0; s <= n; s++ {
+			binary.LittleEndian.PutUint32(buf, uint32(block[s]))
+			for c := 0; c < nclass; c++ {
+				binary.LittleEndian.PutUint32(buf[4*(c+1):], uint32(block[target(s, c)]))
+			}
+			key := string(buf)
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			next[s] = id
+		}
+
+		block = next
+		if len(ids) == count {
+			break
+		}
+		count = len(ids)
+	}
+
+	// Number the blocks so that the start state is 0 and the dead block is dropped.
+	number := make([]int, count)
+	for i := range number {
+		number[i] = Dead
+	}
+	deadBlock := block[dead]
+	states := 0
+	for s := 0; s < n; s++ {
+		if block[s] != deadBlock && number[block[s]] == Dead {
+			number[block[s]] = states
+			states++
+		}
+	}
+
+	d := &DFA{
+		bounds:  b.bounds,
+		nclass:  nclass,
+		start:   number[block[0]],
+		trans:   make([]int32, states*nclass),
+		accept:  make([]int32, states),
+		accepts: make([][]int, states),
+	}
+
+	for s := 0; s < n; s++ {
+		id := number[block[s]]
+		if id == Dead || d.accepts[id] != nil {
+			continue
+		}
+		d.accepts[id] = b.accepts[s]
+		d.accept[id] = -1
+		if len(b.accepts[s]) > 0 {
+			d.accept[id] = int32(b.accepts[s][0])
+		}
+		for c := 0; c < nclass; c++ {
+			d.trans[id*nclass+c] = int32(number[block[target(s, c)]])
+		}
+	}
+
+	for r := range d.ascii {
+		d.ascii[r] = int32(classOf(d.bounds, rune(r)))
+	}
+
+	return d
+}
+
+// example returns a printable rune from class c if it has one.
+func (d *DFA) example(c int) rune {
+	r := d.bounds[c]
+	if r < ' ' && (c+1 == len(d.bounds) || d.bounds[c+1] > ' ') {
+		return ' '
+	}
+	return r
+}
+
+func classOf(bounds []rune, r rune) int {
+	return sort.Search(len(bounds), func(i int) bool { return bounds[i] > r }) - 1
+}
+
+// Start returns the start state, or Dead if no pattern can match anything.
+func (d *DFA) Start() int {
+	return d.start
+}
+
+// Next returns the state reached from state on r.
+func (d *DFA) Next(state int, r rune) int {
+	if state == Dead {
+		return Dead
+	}
+
+	var c int
+	if r >= 0 && r < utf8.RuneSelf {
+		c = int(d.ascii[r])
+	} else {
+		c = classOf(d.bounds, r)
+	}
+
+	return int(d.trans[state*d.nclass+c])
+}
+
+// Accept returns the lowest-numbered pattern that accepts in state, or -1 if there's none.
+func (d *DFA) Accept(state int) int {
+	if state == Dead {
+		return -1
+	}
+	return int(d.accept[state])
+}
+
+// NumStates returns the number of states, not counting the dead state.
+func (d *DFA) NumStates() int {
+	return len(d.accept)
+}
+
+// Ambiguities returns every set of patterns that accept the same input, along with the shortest
+// such input.
+func (d *DFA) Ambiguities() []Ambiguity {
+	// Breadth-first search finds the shortest input that reaches each state.
+	examples := make([]string, len(d.accept))
+	seen := make([]bool, len(d.accept))
+	if d.start == Dead {
+		return nil
+	}
+	seen[d.start] = true
+	queue := []int{d.start}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for c := 0; c < d.nclass; c++ {
+			next := int(d.trans[s*d.nclass+c])
+			if next == Dead || seen[next] {
+				continue
+			}
+			seen[next] = true
+			examples[next] = examples[s] + string(d.example(c))
+			queue = append(queue, next)
+		}
+	}
+
+	reported := make(map[string]bool)
+	ambiguities := make([]Ambiguity, 0)
+	for s, accepts := range d.accepts {
+		key := fmt.Sprint(accepts)
+		if len(accepts) < 2 || !seen[s] || reported[key] {
+			continue
+		}
+		reported[key] = true
+		ambiguities = append(ambiguities, Ambiguity{Patterns: accepts, Example: examples[s]})
+	}
+
+	return ambiguities
+}
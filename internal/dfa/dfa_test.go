@@ -0,0 +1,108 @@
+package dfa
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// longest returns the length of the longest match of d at the start of s and the pattern that
+// matched, or -1 for both if nothing did.
+func longest(d *DFA, s string) (int, int) {
+	end, pattern := -1, -1
+	state := d.Start()
+	for i, r := range s {
+		if state = d.Next(state, r); state == Dead {
+			break
+		}
+		if p := d.Accept(state); p >= 0 {
+			end, pattern = i+len(string(r)), p
+		}
+	}
+	return end, pattern
+}
+
+func TestMatch(t *testing.T) {
+	d, err := Compile([]string{`if`, `==`, `=`, `\pL\w*`, `[0-9]+(\.[0-9]+)?`, `(?i)select`})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		input   string
+		end     int
+		pattern int
+	}{
+		{"if x", 2, 0},  // a keyword ties with an identifier, and the earlier pattern wins
+		{"iffy", 4, 3},  // the longest match wins over an earlier pattern
+		{"=== x", 2, 1}, // operators too
+		{"= x", 1, 2},
+		{"é1 x", 3, 3},  // classes cover runes outside ASCII
+		{"3.14.", 4, 4}, // the match backs up to the last accepting state
+		{"3.x", 1, 4},
+		{"SeLeCt", 6, 3},   // case folding adds runes to a class, and the identifier comes first
+		{"if\u3000", 2, 0}, // whitespace outside ASCII isn't a word character
+		{"+", -1, -1},      // nothing matches
+		{"", -1, -1},
+	}
+
+	for _, tt := range tests {
+		end, pattern := longest(d, tt.input)
+		assert.Equal(t, tt.end, end, tt.input)
+		assert.Equal(t, tt.pattern, pattern, tt.input)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		states   int
+	}{
+		{[]string{`a|b`}, 2},
+		{[]string{`[ab]`}, 2},
+		{[]string{`(a|b)*c`}, 2},
+		{[]string{`abc|xbc`}, 4},
+		{[]string{`a+`, `aa*`}, 2}, // the same language from two patterns
+		{[]string{`a`, `b`}, 3},    // the same length, but accepting different patterns
+		{[]string{`x*`}, 1},
+	}
+
+	for _, tt := range tests {
+		d, err := Compile(tt.patterns)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.states, d.NumStates(), "%q", tt.patterns)
+	}
+
+	d, err := Compile([]string{`[^\x00-\x{10FFFF}]`})
+	assert.NoError(t, err)
+	assert.Equal(t, Dead, d.Start())
+	assert.Equal(t, Dead, d.Next(d.Start(), 'a'))
+	assert.Equal(t, -1, d.Accept(d.Start()))
+}
+
+func TestAmbiguities(t *testing.T) {
+	d, err := Compile([]string{`if`, `[a-z]+`, `[0-9]+`, `\d+`})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Ambiguity{
+		{Patterns: []int{2, 3}, Example: "0"},
+		{Patterns: []int{0, 1}, Example: "if"},
+	}, d.Ambiguities())
+
+	d, err = Compile([]string{`if`, `else`})
+	assert.NoError(t, err)
+	assert.Empty(t, d.Ambiguities())
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{`a\b`, `\Ba`, `a$`, `(?m)^a`, `\Aa\z`} {
+		_, err := Compile([]string{`x`, pattern})
+		assert.ErrorContains(t, err, "empty-width assertions other than ^ aren't supported", pattern)
+	}
+
+	_, err := Compile([]string{`a(`})
+	assert.ErrorContains(t, err, `pattern "a("`)
+
+	d, err := Compile([]string{`^a`})
+	assert.NoError(t, err)
+	end, pattern := longest(d, "aa")
+	assert.Equal(t, 1, end)
+	assert.Equal(t, 0, pattern)
+}
@@ -0,0 +1,120 @@
+// Command gentypes generates a table of the token types declared in a Go file, named after their
+// constants and described by their trailing comments, e.g.:
+//
+//	ADD // +
+//
+// names the type "ADD" and gives it the text "+". It's run by go generate, which sets $GOFILE to
+// the file to read.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	out := flag.String("out", "types.go", "file to write the table to")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("gentypes: ")
+
+	in := os.Getenv("GOFILE")
+	if in == "" {
+		log.Fatal("$GOFILE isn't set; run with go generate")
+	}
+
+	src, err := generate(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the source of the table of the token types declared in the file at path.
+func generate(path string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Types are token.TokenType outside of the token package.
+	qualifier := "token."
+	if file.Name.Name == "token" {
+		qualifier = ""
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gentypes from %s; DO NOT EDIT.\n\n", path)
+	fmt.Fprintf(&buf, "package %s\n\n", file.Name.Name)
+	if qualifier != "" {
+		fmt.Fprintf(&buf, "import \"github.com/rdeusser/parsekit/token\"\n\n")
+	}
+	fmt.Fprintf(&buf, "// types are the names of the token types declared in %s.\n", path)
+	fmt.Fprintf(&buf, "var types = []struct {\n\ttyp  %sTokenType\n\tinfo %sInfo\n}{\n", qualifier, qualifier)
+
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			continue
+		}
+
+		// A spec without a type or values continues the one before it.
+		isType := false
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			switch {
+			case spec.Type != nil:
+				isType = isTokenType(spec.Type)
+			case len(spec.Values) > 0:
+				// Untyped constants like token.LiteralStart mark ranges rather than types.
+				isType = false
+			}
+			if !isType {
+				continue
+			}
+
+			for _, name := range spec.Names {
+				text := ""
+				if spec.Comment != nil {
+					text = strings.TrimSpace(spec.Comment.Text())
+				}
+				if text == name.Name {
+					text = ""
+				}
+				if text == "" {
+					fmt.Fprintf(&buf, "\t{%s, %sInfo{Name: %q}},\n", name.Name, qualifier, name.Name)
+				} else {
+					fmt.Fprintf(&buf, "\t{%s, %sInfo{Name: %q, Text: %q}},\n", name.Name, qualifier, name.Name, text)
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(&buf, "}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+// isTokenType reports whether expr is TokenType or token.TokenType.
+func isTokenType(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name == "TokenType"
+	case *ast.SelectorExpr:
+		return expr.Sel.Name == "TokenType"
+	}
+	return false
+}
@@ -30,6 +30,11 @@ func (l *Logger) SetLevel(level LogLevel) {
 	l.l = level
 }
 
+// DebugEnabled reports whether debug messages are written.
+func (l Logger) DebugEnabled() bool {
+	return l.l <= Debug
+}
+
 func (l Logger) Debug(msg string, args ...any) {
 	if l.l <= Debug {
 		fmt.Fprintln(l.w, format(Debug, msg, args...))
@@ -1,36 +1,43 @@
 package loopdetector
 
-import (
-	"sync"
-)
+// DefaultLimit is the number of consecutive actions that may end without making progress before
+// a loop is reported.
+const DefaultLimit = 0
 
+// Detector detects when a lexer stops making progress through its input. It's checked
+// synchronously after every action, so it never needs a goroutine or a timer.
 type Detector struct {
-	curPos  int
-	prevPos int
-	count   int
-	limit   int
+	pos   int
+	count int
+	limit int
 }
 
-func New() *Detector {
+func New(limit int) *Detector {
 	return &Detector{
-		limit: 10,
+		limit: limit,
 	}
 }
 
-func (d *Detector) Detect(mu *sync.Mutex, pos int) {
-	mu.Lock()
-	defer mu.Unlock()
-	if d.curPos == pos {
-		d.increment()
-	} else {
+// Mark records the position before an action runs.
+func (d *Detector) Mark(pos int) {
+	d.pos = pos
+}
+
+// Detect records the position after an action has run. Actions that end at or before the marked
+// position are counted as not having made progress.
+func (d *Detector) Detect(pos int) {
+	if pos > d.pos {
 		d.reset()
+	} else {
+		d.increment()
 	}
-	d.prevPos = d.curPos
-	d.curPos = pos
 }
 
+// Reset forgets any actions that haven't made progress.
+func (d *Detector) Reset() { d.reset() }
+
 func (d *Detector) IsLooping() bool {
-	return d.count == d.limit
+	return d.count > d.limit
 }
 func (d *Detector) increment() { d.count++ }
 func (d *Detector) reset()     { d.count = 0 }
@@ -5,16 +5,91 @@ import (
 	"github.com/rdeusser/parsekit/token"
 )
 
+// Semicolons inserts semicolons where Go's grammar ends a statement at a newline.
+var Semicolons = lexer.Semicolons{
+	Type: SEMICOLON,
+	After: map[token.TokenType]bool{
+		token.IDENT:  true,
+		token.NUMBER: true,
+		token.FLOAT:  true,
+		token.IMAG:   true,
+		token.CHAR:   true,
+		token.STRING: true,
+		BREAK:        true,
+		CONTINUE:     true,
+		FALLTHROUGH:  true,
+		RETURN:       true,
+		INC:          true,
+		DEC:          true,
+		RPAREN:       true,
+		RBRACK:       true,
+		RBRACE:       true,
+	},
+}
+
+// NewLexer returns a lexer for Go that inserts semicolons as described in the Go spec and names
+// its token types with Types.
 func NewLexer(options ...lexer.Option) *lexer.Lexer {
-	config := lexer.Config{
+	return lexer.New(newConfig(), append(defaultOptions(), options...)...)
+}
+
+// NewCompiledLexer returns a lexer for Go that scans with a DFA compiled from regular expressions
+// for Go's tokens instead of trying each rule in turn. See lexer.Compile.
+func NewCompiledLexer(options ...lexer.Option) (*lexer.Lexer, error) {
+	config := newConfig()
+	config.Rules = []lexer.Rule{
+		lexer.MustRegexpRule("Identifier", `\pL[\pL\pN]*`, token.IDENT),
+		lexer.MustRegexpRule("String", `"(?:[^"\\\n]|\\[^\n])*"`, token.STRING),
+		lexer.MustRegexpRule("RawString", "`[^`]*`", token.STRING),
+		lexer.MustRegexpRule("Char", `'(?:[^'\\\n]|\\(?:[abfnrtv\\'"]|x[0-9a-fA-F]{2}|u[0-9a-fA-F]{4}|U[0-9a-fA-F]{8}|[0-7]{3}))'`, token.CHAR),
+		lexer.MustRegexpRule("Imaginary", `(?:`+decimals+`|`+intLit+`|`+floatLit+`)i`, token.IMAG),
+		lexer.MustRegexpRule("Float", floatLit, token.FLOAT),
+		lexer.MustRegexpRule("Number", intLit, token.NUMBER),
+	}
+	return lexer.Compile(config, append(defaultOptions(), options...)...)
+}
+
+// defaultOptions returns the options every lexer for Go starts with.
+func defaultOptions() []lexer.Option {
+	return []lexer.Option{lexer.WithSemicolons(Semicolons), lexer.WithTypes(Types)}
+}
+
+// Patterns for Go's numeric literals, following the Go spec.
+const (
+	decimals    = `[0-9](?:_?[0-9])*`
+	hexDigits   = `[0-9a-fA-F](?:_?[0-9a-fA-F])*`
+	decimalExp  = `[eE][+-]?` + decimals
+	hexExp      = `[pP][+-]?` + decimals
+	intLit      = `0(?:[bB](?:_?[01])+|[oO](?:_?[0-7])+|[xX](?:_?[0-9a-fA-F])+|(?:_?[0-7])*)|[1-9](?:_?[0-9])*`
+	decimalLit  = decimals + `\.(?:` + decimals + `)?(?:` + decimalExp + `)?|` + decimals + decimalExp + `|\.` + decimals + `(?:` + decimalExp + `)?`
+	hexFloatLit = `0[xX](?:_?` + hexDigits + `(?:\.(?:` + hexDigits + `)?)?|\.` + hexDigits + `)` + hexExp
+	floatLit    = decimalLit + `|` + hexFloatLit
+)
+
+func newConfig() lexer.Config {
+	return lexer.Config{
 		SkipWhitespace: true,
+		LineComments:   []string{"//"},
+		BlockComments:  []lexer.BlockComment{{Start: "/*", End: "*/"}},
 		Rules: []lexer.Rule{
 			{Name: "LexIdentifier", Match: lexer.IsLetter, Action: lexer.LexIdentifier},
 			{Name: "LexString", Match: lexer.IsDoubleQuote, Action: lexer.LexString},
 			{Name: "LexRawString", Match: lexer.IsBackQuote, Action: lexer.LexRawString},
-			{Name: "LexNumber", Match: lexer.IsNumber, Action: lexer.LexNumber},
+			{Name: "LexChar", Match: lexer.IsSingleQuote, Action: lexer.LexChar},
+			{Name: "LexNumber", Match: lexer.IsNumberStart, Action: lexer.LexNumber},
 			{Name: "LexOperator", Match: lexer.IsOperator, Action: lexer.LexOperator},
 		},
+		Numbers: lexer.NumberConfig{
+			Hex:         true,
+			Octal:       true,
+			Binary:      true,
+			LegacyOctal: true,
+			Float:       true,
+			Exponent:    true,
+			HexFloat:    true,
+			Separator:   '_',
+			Suffixes:    map[string]token.TokenType{"i": token.IMAG},
+		},
 		Operators: map[string]token.TokenType{
 			"+":   ADD,
 			"-":   SUB,
@@ -56,7 +131,7 @@ func NewLexer(options ...lexer.Option) *lexer.Lexer {
 			"(":   LPAREN,
 			"[":   LBRACK,
 			"{":   LBRACE,
-			"":    COMMA,
+			",":   COMMA,
 			".":   PERIOD,
 			")":   RPAREN,
 			"]":   RBRACK,
@@ -92,9 +167,4 @@ func NewLexer(options ...lexer.Option) *lexer.Lexer {
 			"var":         VAR,
 		},
 	}
-	lexer := lexer.New(config)
-	for _, option := range options {
-		option(lexer)
-	}
-	return lexer
 }
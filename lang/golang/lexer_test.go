@@ -0,0 +1,267 @@
+package golang
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdeusser/parsekit/ast"
+	"github.com/rdeusser/parsekit/lexer"
+	"github.com/rdeusser/parsekit/parser"
+	"github.com/rdeusser/parsekit/token"
+)
+
+const source = `package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cache is a concurrency-safe cache with expiring entries.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	ttl     time.Duration
+}
+
+type entry struct {
+	value   any
+	expires time.Time
+}
+
+/* New returns an empty cache whose entries
+   expire after ttl. */
+func New(ttl time.Duration) *Cache {
+	return &Cache{entries: make(map[string]entry), ttl: ttl}
+}
+
+func (c *Cache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *Cache) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *Cache) String() string {
+	var sb strings.Builder
+	for key, e := range c.entries {
+		sb.WriteString(fmt.Sprintf("%s=%v (%.2f)\n", key, e.value, 1.5*float64(len(key))))
+	}
+	return sb.String() + ` + "`raw\nstring`" + `
+}
+
+var limits = []any{
+	0, 42, 0755, 0o17, 0b1010, 0xBadFace, 1_000_000, 0x_1f,
+	3.14, 1., .5, 1e-9, 6.02e+23, 0x1p-2, 0x1.8P1, 0x.8p0,
+	3i, 0i, 0777i, 1.5e3i, 0x10i,
+}
+
+var runes = []rune{'a', 'é', '🎉', '\'', '\n', '\x41', '\101', '\u00e9', '\U0001F389'}
+`
+
+func TestTypes(t *testing.T) {
+	assert.Equal(t, "ADD", Types.Name(ADD))
+	assert.Equal(t, "IDENT", Types.Name(token.IDENT))
+
+	// Go's names are kept out of the global registry, so other languages can use the same values.
+	_, ok := token.Types.Lookup(ADD)
+	assert.False(t, ok)
+
+	// Go's lexers name their types with it, and so do the errors of parsers using them.
+	l := NewLexer()
+	assert.Equal(t, Types, l.Types())
+
+	tokens, err := l.Lex("+")
+	assert.NoError(t, err)
+	assert.Equal(t, "Token{Type: ADD, Start: 1:1, End: 1:2}", tokens[0].Format(l.Types()))
+
+	_, err = parser.New(l, parser.Config{}).Parse("x + y")
+	assert.EqualError(t, err, `no rule to handle token "x" at Token{Type: IDENT, Start: 1:1, End: 1:2}`)
+
+	rules := parser.Config{Rules: []parser.Rule{{
+		Name:   "Ident",
+		Match:  func(tok token.Token) bool { return tok.Type == token.IDENT },
+		Action: func(p *parser.Parser, tok token.Token) (ast.Node, error) { return &ast.Identifier{}, nil },
+	}}}
+	_, err = parser.New(l, rules).Parse("x + y")
+	assert.EqualError(t, err, `no rule to handle token "+" at Token{Type: ADD, Start: 1:3, End: 1:4}`)
+}
+
+func TestCompiledLexer(t *testing.T) {
+	want, err := NewLexer().Lex(source)
+	assert.NoError(t, err)
+
+	l, err := NewCompiledLexer()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	got, err := l.Lex(source)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestSemicolons(t *testing.T) {
+	input := "x := f(a)\nreturn\n}\ny++ // c\nz /* a\nb */ w\nif x {\n\treturn\n}"
+
+	tokens, err := NewLexer().Lex(input)
+	assert.NoError(t, err)
+
+	// Inserted semicolons have no text.
+	assert.Equal(t, []string{
+		"x", ":=", "f", "(", "a", ")", "",
+		"return", "",
+		"}", "",
+		"y", "++", "// c", "",
+		"z", "/* a\nb */", "", "w", "",
+		"if", "x", "{",
+		"return", "",
+		"}", "",
+	}, literals(tokens))
+}
+
+// benchmarkInputs returns the inputs to benchmark lexers with: the source above, and a realistic Go
+// file from testdata, each repeated to a similar size.
+func benchmarkInputs(b *testing.B) map[string]string {
+	data, err := os.ReadFile("testdata/bench.go")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return map[string]string{
+		"source":   strings.Repeat(source, 100),
+		"testdata": strings.Repeat(string(data)+"\n", 7),
+	}
+}
+
+func BenchmarkLexer(b *testing.B) {
+	compiled, err := NewCompiledLexer()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	lexers := map[string]*lexer.Lexer{
+		"interpreter": NewLexer(),
+		"compiled":    compiled,
+	}
+
+	for inputName, input := range benchmarkInputs(b) {
+		for name, l := range lexers {
+			b.Run(inputName+"/"+name, func(b *testing.B) {
+				b.SetBytes(int64(len(input)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := l.Lex(input); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+
+		b.Run(inputName+"/spans", func(b *testing.B) {
+			l := NewLexer()
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := l.LexSpans(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(inputName+"/stream", func(b *testing.B) {
+			l := NewLexer()
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				l.Init(strings.NewReader(input))
+				for {
+					_, err := l.NextToken()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+
+		b.Run(inputName+"/relex", func(b *testing.B) {
+			l := NewLexer()
+			tokens, err := l.Lex(input)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			// Type a character into the middle of an identifier, the way an editor would send it.
+			i := len(tokens) / 2
+			for tokens[i].Type != token.IDENT {
+				i++
+			}
+			edit := lexer.Edit{Start: tokens[i].Start.Pos + 1, End: tokens[i].Start.Pos + 1, Text: "x"}
+			edited := input[:edit.Start] + edit.Text + input[edit.End:]
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := l.Relex(input, edited, tokens, edit); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRelex(t *testing.T) {
+	l := NewLexer()
+
+	tokens, err := l.Lex(source)
+	assert.NoError(t, err)
+
+	// Remove every line, and then put it back as if typed into an empty line.
+	for start := 0; start < len(source); {
+		end := start + strings.IndexByte(source[start:], '\n') + 1
+
+		edited := source[:start] + "\n" + source[end:]
+		want, wantErr := l.Clone().Lex(edited)
+		got, _, err := l.Relex(source, edited, tokens, lexer.Edit{Start: start, End: end, Text: "\n"})
+		if wantErr != nil {
+			assert.EqualError(t, err, wantErr.Error())
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, want, got)
+		}
+
+		if err == nil {
+			got, _, err = l.Relex(edited, source, got, lexer.Edit{Start: start, End: start + 1, Text: source[start:end]})
+			assert.NoError(t, err)
+			assert.Equal(t, tokens, got)
+		}
+
+		start = end
+	}
+}
+
+// literals returns the literals of tokens.
+func literals(tokens []token.Token) []string {
+	got := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		got = append(got, tok.Literal)
+	}
+	return got
+}
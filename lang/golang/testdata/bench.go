@@ -0,0 +1,294 @@
+// Package store is a fixture for benchmarking the Go lexer: a small, realistic program that uses
+// most of Go's tokens. It's never compiled.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a key isn't in the store.
+var ErrNotFound = errors.New("store: key not found")
+
+const (
+	defaultCapacity = 1 << 10
+	defaultTTL      = 5 * time.Minute
+	maxKeyLen       = 0x100
+	loadFactor      = 0.75
+	mask            = 0b1111
+	perm            = 0o644
+	big             = 1e9
+	tiny            = 6.02e-23
+	hexFloat        = 0x1p-2
+	imaginary       = 3i
+)
+
+// Entry is a value in the store along with when it expires.
+type Entry[V any] struct {
+	Key     string    `json:"key"`
+	Value   V         `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+	prev    *Entry[V]
+	next    *Entry[V]
+}
+
+// Expired reports whether the entry has expired at now.
+func (e *Entry[V]) Expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+// Store is a least-recently-used cache with expiring entries. It's safe for concurrent use.
+type Store[V any] struct {
+	mu       sync.RWMutex
+	entries  map[string]*Entry[V]
+	head     *Entry[V]
+	tail     *Entry[V]
+	capacity int
+	ttl      time.Duration
+	hits     uint64
+	misses   uint64
+	now      func() time.Time
+}
+
+// Option configures a store.
+type Option[V any] func(*Store[V])
+
+// WithCapacity sets how many entries the store keeps.
+func WithCapacity[V any](n int) Option[V] {
+	return func(s *Store[V]) {
+		if n > 0 {
+			s.capacity = n
+		}
+	}
+}
+
+// WithTTL sets how long entries live.
+func WithTTL[V any](ttl time.Duration) Option[V] {
+	return func(s *Store[V]) {
+		s.ttl = ttl
+	}
+}
+
+// New creates a store.
+func New[V any](options ...Option[V]) *Store[V] {
+	s := &Store[V]{
+		entries:  make(map[string]*Entry[V], defaultCapacity),
+		capacity: defaultCapacity,
+		ttl:      defaultTTL,
+		now:      time.Now,
+	}
+	for i := range options {
+		options[i](s)
+	}
+	return s
+}
+
+// Get returns the value of key, if it's there and hasn't expired.
+func (s *Store[V]) Get(key string) (V, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero V
+	e, ok := s.entries[key]
+	if !ok {
+		s.misses++
+		return zero, ErrNotFound
+	}
+	if e.Expired(s.now()) {
+		s.remove(e)
+		s.misses++
+		return zero, fmt.Errorf("%w: %q expired", ErrNotFound, key)
+	}
+
+	s.moveToFront(e)
+	s.hits++
+	return e.Value, nil
+}
+
+// Set stores value under key, evicting the least recently used entry if the store is full.
+func (s *Store[V]) Set(key string, value V) error {
+	if len(key) == 0 || len(key) > maxKeyLen {
+		return fmt.Errorf("store: invalid key length %d", len(key))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.Value = value
+		e.Expires = s.now().Add(s.ttl)
+		s.moveToFront(e)
+		return nil
+	}
+
+	for len(s.entries) >= s.capacity && s.tail != nil {
+		s.remove(s.tail)
+	}
+
+	e := &Entry[V]{Key: key, Value: value, Expires: s.now().Add(s.ttl)}
+	s.entries[key] = e
+	s.pushFront(e)
+	return nil
+}
+
+func (s *Store[V]) pushFront(e *Entry[V]) {
+	e.prev, e.next = nil, s.head
+	if s.head != nil {
+		s.head.prev = e
+	}
+	s.head = e
+	if s.tail == nil {
+		s.tail = e
+	}
+}
+
+func (s *Store[V]) remove(e *Entry[V]) {
+	switch {
+	case e.prev != nil:
+		e.prev.next = e.next
+	default:
+		s.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		s.tail = e.prev
+	}
+	delete(s.entries, e.Key)
+}
+
+func (s *Store[V]) moveToFront(e *Entry[V]) {
+	if s.head == e {
+		return
+	}
+	s.remove(e)
+	s.entries[e.Key] = e
+	s.pushFront(e)
+}
+
+// Stats returns the hit ratio as a percentage, and the keys sorted by name.
+func (s *Store[V]) Stats() (float64, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	total := s.hits + s.misses
+	if total == 0 {
+		return 0, keys
+	}
+	return float64(s.hits) / float64(total) * 100, keys
+}
+
+// Sweep removes expired entries until ctx is done, checking every interval.
+func (s *Store[V]) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for e := s.tail; e != nil; {
+				prev := e.prev
+				if e.Expired(now) {
+					s.remove(e)
+				}
+				e = prev
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Handler serves the store over HTTP: GET /key reads a value and PUT /key writes one.
+func Handler(s *Store[json.RawMessage]) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+
+		switch r.Method {
+		case http.MethodGet:
+			value, err := s.Get(key)
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write(value)
+		case http.MethodPut:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil || !json.Valid(body) {
+				http.Error(w, "invalid JSON body\n", http.StatusBadRequest)
+				return
+			}
+			if err := s.Set(key, body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, `method not allowed`, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// parseSize parses sizes like "64k", "2M", or "1024".
+func parseSize(s string) (int, error) {
+	shift := 0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		shift = 10
+	case 'm', 'M':
+		shift = 20
+	case 'g', 'G':
+		shift = 30
+	}
+	if shift != 0 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %v", s, err)
+	}
+	if n < 0 || n > 1<<(62-shift) {
+		return 0, fmt.Errorf("size %s out of range", s)
+	}
+	return n << shift, nil
+}
+
+/*
+checksum computes a simple rolling checksum of data, the way rsync's weak checksum does, so that
+the benchmark has some arithmetic operators in it: a, b, and their combination.
+*/
+func checksum(data []byte) uint32 {
+	var a, b uint32 = 1, 0
+	for i, c := range data {
+		a = (a + uint32(c)) % 65521
+		b = (b + a) % 65521
+		if i%5552 == 0 {
+			a, b = a&0xffff, b&^0xffff0000
+		}
+	}
+	a ^= b >> 3
+	b |= a << 7
+	return b<<16 | a
+}
+
+var escapes = []rune{'\n', '\t', '\'', '\\', '\x7f', 'é', '\U0001F600', 'é', '🎉'}
@@ -4,6 +4,8 @@ import (
 	"github.com/rdeusser/parsekit/token"
 )
 
+//go:generate go run ../../internal/gentypes -out types.go
+
 const (
 	// Operators and delimiters
 	ADD token.TokenType = token.OperatorStart + iota // +
@@ -63,33 +65,43 @@ const (
 	COLON     // :
 
 	// Keywords
-	BREAK token.TokenType = token.KeywordStart + iota
-	CASE
-	CHAN
-	CONST
-	CONTINUE
-
-	DEFAULT
-	DEFER
-	ELSE
-	FALLTHROUGH
-	FOR
-
-	FUNC
-	GO
-	GOTO
-	IF
-	IMPORT
-
-	INTERFACE
-	MAP
-	PACKAGE
-	RANGE
-	RETURN
-
-	SELECT
-	STRUCT
-	SWITCH
-	TYPE
-	VAR
+	BREAK    token.TokenType = token.KeywordStart + iota // break
+	CASE                                                 // case
+	CHAN                                                 // chan
+	CONST                                                // const
+	CONTINUE                                             // continue
+
+	DEFAULT     // default
+	DEFER       // defer
+	ELSE        // else
+	FALLTHROUGH // fallthrough
+	FOR         // for
+
+	FUNC   // func
+	GO     // go
+	GOTO   // goto
+	IF     // if
+	IMPORT // import
+
+	INTERFACE // interface
+	MAP       // map
+	PACKAGE   // package
+	RANGE     // range
+	RETURN    // return
+
+	SELECT // select
+	STRUCT // struct
+	SWITCH // switch
+	TYPE   // type
+	VAR    // var
 )
+
+// Types names Go's token types. It falls back on token.Types for the built-in types, so that Go's
+// names don't clash with those of other languages, whose types have the same values.
+var Types = token.NewRegistry(token.Types)
+
+func init() {
+	for _, t := range types {
+		Types.MustRegister(t.typ, t.info)
+	}
+}
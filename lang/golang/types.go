@@ -0,0 +1,84 @@
+// Code generated by gentypes from token.go; DO NOT EDIT.
+
+package golang
+
+import "github.com/rdeusser/parsekit/token"
+
+// types are the names of the token types declared in token.go.
+var types = []struct {
+	typ  token.TokenType
+	info token.Info
+}{
+	{ADD, token.Info{Name: "ADD", Text: "+"}},
+	{SUB, token.Info{Name: "SUB", Text: "-"}},
+	{MUL, token.Info{Name: "MUL", Text: "*"}},
+	{QUO, token.Info{Name: "QUO", Text: "/"}},
+	{REM, token.Info{Name: "REM", Text: "%"}},
+	{AND, token.Info{Name: "AND", Text: "&"}},
+	{OR, token.Info{Name: "OR", Text: "|"}},
+	{XOR, token.Info{Name: "XOR", Text: "^"}},
+	{SHL, token.Info{Name: "SHL", Text: "<<"}},
+	{SHR, token.Info{Name: "SHR", Text: ">>"}},
+	{AND_NOT, token.Info{Name: "AND_NOT", Text: "&^"}},
+	{ADD_ASSIGN, token.Info{Name: "ADD_ASSIGN", Text: "+="}},
+	{SUB_ASSIGN, token.Info{Name: "SUB_ASSIGN", Text: "-="}},
+	{MUL_ASSIGN, token.Info{Name: "MUL_ASSIGN", Text: "*="}},
+	{QUO_ASSIGN, token.Info{Name: "QUO_ASSIGN", Text: "/="}},
+	{REM_ASSIGN, token.Info{Name: "REM_ASSIGN", Text: "%="}},
+	{AND_ASSIGN, token.Info{Name: "AND_ASSIGN", Text: "&="}},
+	{OR_ASSIGN, token.Info{Name: "OR_ASSIGN", Text: "|="}},
+	{XOR_ASSIGN, token.Info{Name: "XOR_ASSIGN", Text: "^="}},
+	{SHL_ASSIGN, token.Info{Name: "SHL_ASSIGN", Text: "<<="}},
+	{SHR_ASSIGN, token.Info{Name: "SHR_ASSIGN", Text: ">>="}},
+	{AND_NOT_ASSIGN, token.Info{Name: "AND_NOT_ASSIGN", Text: "&^="}},
+	{LAND, token.Info{Name: "LAND", Text: "&&"}},
+	{LOR, token.Info{Name: "LOR", Text: "||"}},
+	{ARROW, token.Info{Name: "ARROW", Text: "<-"}},
+	{INC, token.Info{Name: "INC", Text: "++"}},
+	{DEC, token.Info{Name: "DEC", Text: "--"}},
+	{EQL, token.Info{Name: "EQL", Text: "=="}},
+	{LSS, token.Info{Name: "LSS", Text: "<"}},
+	{GTR, token.Info{Name: "GTR", Text: ">"}},
+	{ASSIGN, token.Info{Name: "ASSIGN", Text: "="}},
+	{NOT, token.Info{Name: "NOT", Text: "!"}},
+	{NEQ, token.Info{Name: "NEQ", Text: "!="}},
+	{LEQ, token.Info{Name: "LEQ", Text: "<="}},
+	{GEQ, token.Info{Name: "GEQ", Text: ">="}},
+	{DEFINE, token.Info{Name: "DEFINE", Text: ":="}},
+	{ELLIPSIS, token.Info{Name: "ELLIPSIS", Text: "..."}},
+	{LPAREN, token.Info{Name: "LPAREN", Text: "("}},
+	{LBRACK, token.Info{Name: "LBRACK", Text: "["}},
+	{LBRACE, token.Info{Name: "LBRACE", Text: "{"}},
+	{COMMA, token.Info{Name: "COMMA", Text: ","}},
+	{PERIOD, token.Info{Name: "PERIOD", Text: "."}},
+	{RPAREN, token.Info{Name: "RPAREN", Text: ")"}},
+	{RBRACK, token.Info{Name: "RBRACK", Text: "]"}},
+	{RBRACE, token.Info{Name: "RBRACE", Text: "}"}},
+	{SEMICOLON, token.Info{Name: "SEMICOLON", Text: ";"}},
+	{COLON, token.Info{Name: "COLON", Text: ":"}},
+	{BREAK, token.Info{Name: "BREAK", Text: "break"}},
+	{CASE, token.Info{Name: "CASE", Text: "case"}},
+	{CHAN, token.Info{Name: "CHAN", Text: "chan"}},
+	{CONST, token.Info{Name: "CONST", Text: "const"}},
+	{CONTINUE, token.Info{Name: "CONTINUE", Text: "continue"}},
+	{DEFAULT, token.Info{Name: "DEFAULT", Text: "default"}},
+	{DEFER, token.Info{Name: "DEFER", Text: "defer"}},
+	{ELSE, token.Info{Name: "ELSE", Text: "else"}},
+	{FALLTHROUGH, token.Info{Name: "FALLTHROUGH", Text: "fallthrough"}},
+	{FOR, token.Info{Name: "FOR", Text: "for"}},
+	{FUNC, token.Info{Name: "FUNC", Text: "func"}},
+	{GO, token.Info{Name: "GO", Text: "go"}},
+	{GOTO, token.Info{Name: "GOTO", Text: "goto"}},
+	{IF, token.Info{Name: "IF", Text: "if"}},
+	{IMPORT, token.Info{Name: "IMPORT", Text: "import"}},
+	{INTERFACE, token.Info{Name: "INTERFACE", Text: "interface"}},
+	{MAP, token.Info{Name: "MAP", Text: "map"}},
+	{PACKAGE, token.Info{Name: "PACKAGE", Text: "package"}},
+	{RANGE, token.Info{Name: "RANGE", Text: "range"}},
+	{RETURN, token.Info{Name: "RETURN", Text: "return"}},
+	{SELECT, token.Info{Name: "SELECT", Text: "select"}},
+	{STRUCT, token.Info{Name: "STRUCT", Text: "struct"}},
+	{SWITCH, token.Info{Name: "SWITCH", Text: "switch"}},
+	{TYPE, token.Info{Name: "TYPE", Text: "type"}},
+	{VAR, token.Info{Name: "VAR", Text: "var"}},
+}
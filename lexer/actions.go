@@ -2,6 +2,9 @@ package lexer
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/rdeusser/parsekit/token"
 )
@@ -27,47 +30,100 @@ func LexIdentifier(l *Lexer, ch rune) (token.Token, error) {
 	return l.EndRule(tok, nil)
 }
 
+// LexChar lexes a single-quoted character literal, which holds exactly one character or escape
+// sequence.
 func LexChar(l *Lexer, ch rune) (token.Token, error) {
 	tok := l.StartRule(token.CHAR)
 
+	var value []byte
+	var escErr error
+	n := 0
+
 	ch = l.Next()
+	for !IsSingleQuote(ch) {
+		switch {
+		case IsNewline(ch) || IsEOF(ch):
+			return l.EndRule(tok, Error{Lexer: l, Msg: "character literal not terminated", Pos: tok.Start})
+		case ch == '\\':
+			// In a character literal, \x and octal escapes stand for code points rather than bytes.
+			r, _, err := l.escape()
+			if err != nil && escErr == nil {
+				escErr = err
+			}
+			if l.values {
+				value = utf8.AppendRune(value, r)
+			}
+			ch = l.currentChar()
+		default:
+			if l.values {
+				value = utf8.AppendRune(value, ch)
+			}
+			ch = l.Next()
+		}
+		n++
+	}
+
 	ch = l.Next()
 
-	if !IsSingleQuote(ch) {
-		return l.EndRule(tok, Error{Lexer: l, Msg: "chars cannot be more than one character"})
+	switch {
+	case escErr != nil:
+		return l.EndRule(tok, escErr)
+	case n == 0:
+		return l.EndRule(tok, Error{Lexer: l, Msg: "empty character literal", Pos: tok.Start, End: l.curPos})
+	case n > 1:
+		return l.EndRule(tok, Error{Lexer: l, Msg: "character literal has more than one character", Pos: tok.Start, End: l.curPos})
 	}
 
-	ch = l.Next()
+	if l.values {
+		tok.Value = string(value)
+	}
 
 	return l.EndRule(tok, nil)
 }
 
+// LexString lexes a double-quoted string. Escape sequences are checked as they're lexed, but an
+// invalid one is only reported once the whole string has been, so that recovery resumes after it.
 func LexString(l *Lexer, ch rune) (token.Token, error) {
 	tok := l.StartRule(token.STRING)
 
-	prevCh := ch
-	for {
-		prevCh = ch
-		ch = l.Next()
+	var value []byte
+	var escErr error
 
-		if IsNewline(ch) {
-			return l.EndRule(tok, Error{Lexer: l, Msg: "literal newlines aren't valid in a string"})
-		}
-
-		if IsDoubleQuote(ch) {
-			if prevCh == '\\' { // is the quote escaped?
-				prevCh = ch
-				ch = l.Next()
-			} else {
-				ch = l.Next()
-				break
+	ch = l.Next()
+	for !IsDoubleQuote(ch) {
+		switch {
+		case IsNewline(ch):
+			return l.EndRule(tok, Error{Lexer: l, Msg: "literal newlines aren't valid in a string", Pos: tok.Start, End: l.curPos})
+		case IsEOF(ch):
+			return l.EndRule(tok, Error{Lexer: l, Msg: "string literal not terminated", Pos: tok.Start, End: l.curPos})
+		case ch == '\\':
+			r, isByte, err := l.escape()
+			if err != nil && escErr == nil {
+				escErr = err
+			}
+			if l.values {
+				value = appendValue(value, r, isByte)
 			}
+			ch = l.currentChar()
+		default:
+			if l.values {
+				value = utf8.AppendRune(value, ch)
+			}
+			ch = l.Next()
 		}
 	}
 
-	tok, err := l.EndRule(tok, nil)
+	ch = l.Next()
+
+	if escErr != nil {
+		return l.EndRule(tok, escErr)
+	}
+
+	if l.values {
+		tok.Value = string(value)
+	}
 
-	return tok, err
+	return l.EndRule(tok, nil)
 }
 
 func LexRawString(l *Lexer, ch rune) (token.Token, error) {
@@ -75,60 +131,113 @@ func LexRawString(l *Lexer, ch rune) (token.Token, error) {
 
 	for {
 		ch = l.Next()
+		if IsEOF(ch) {
+			return l.EndRule(tok, Error{Lexer: l, Msg: "raw string literal not terminated", Pos: tok.Start, End: l.curPos})
+		}
 		if IsBackQuote(ch) {
 			ch = l.Next()
 			break
 		}
 	}
 
+	if l.values {
+		tok.Value = l.slice(tok.Start.Pos+1, l.curPos.Pos-1)
+	}
+
 	return l.EndRule(tok, nil)
 }
 
-func LexNumber(l *Lexer, ch rune) (token.Token, error) {
-	tok := l.StartRule(token.NUMBER)
+// LexComment lexes a line or block comment as configured by LineComments and BlockComments in the
+// current mode. It moves to the next rule if no comment starts at the current position.
+func LexComment(l *Lexer, ch rune) (token.Token, error) {
+	tok := l.StartRule(token.COMMENT)
 
-	for IsNumber(ch) {
-		ch = l.Next()
-		if ch == '.' {
-			tok.Type = token.FLOAT
+	// Block comments are checked first so that a delimiter like Lua's "--[[" wins over the line
+	// comment prefix "--".
+	for _, block := range l.mode.BlockComments {
+		if !l.hasPrefix(block.Start) {
+			continue
+		}
+
+		ch = l.skip(block.Start)
+		depth := 1
+		for depth > 0 {
+			switch {
+			case IsEOF(ch):
+				return l.EndRule(tok, Error{Lexer: l, Msg: "comment not terminated", Pos: tok.Start})
+			case l.hasPrefix(block.End):
+				ch = l.skip(block.End)
+				depth--
+			case block.Nested && l.hasPrefix(block.Start):
+				ch = l.skip(block.Start)
+				depth++
+			default:
+				ch = l.Next()
+			}
+		}
+
+		return l.EndRule(tok, nil)
+	}
+
+	for _, prefix := range l.mode.LineComments {
+		if !l.hasPrefix(prefix) {
+			continue
+		}
+
+		ch = l.skip(prefix)
+		for !IsNewline(ch) && !IsEOF(ch) {
 			ch = l.Next()
 		}
+
+		return l.EndRule(tok, nil)
 	}
 
-	return l.EndRule(tok, nil)
+	return l.EndRule(tok, Error{Lexer: l, Msg: "not a comment", GotoNextRule: true})
 }
 
+// LexOperator lexes the longest operator at the current position by walking the operator trie of
+// the current mode. Text that starts an operator but doesn't finish one is reported along with the
+// operators it could have been.
 func LexOperator(l *Lexer, ch rune) (token.Token, error) {
 	tok := l.StartRule(token.ILLEGAL)
-	maxLen := longestOperator(l.config.Operators)
-	op := l.Lookahead(maxLen)
 
+	node, pos := l.mode.operators, l.curPos.Pos
+	end := -1
 	for {
-		op = l.Lookahead(maxLen)
-		tok.Type = l.LookupToken(string(op))
-		if maxLen < 0 {
-			return l.EndRule(tok, fmt.Errorf("invalid operator: %s", string(ch)))
+		r, size := l.decode(pos)
+		child, ok := node.children[r]
+		if size == 0 || !ok {
+			break
 		}
-		if tok.Type == token.ILLEGAL {
-			maxLen--
-			continue
+		node, pos = child, pos+size
+		if node.op != "" {
+			end, tok.Type = pos, node.typ
 		}
-		break
 	}
 
-	for i := 0; i < len(op); i++ {
-		_ = l.Next()
+	if end < 0 {
+		if pos == tok.Start.Pos {
+			return l.EndRule(tok, Error{Lexer: l, Msg: fmt.Sprintf("invalid operator %q", ch), Pos: tok.Start})
+		}
+		end = pos
 	}
 
-	return l.EndRule(tok, nil)
-}
+	for l.curPos.Pos < end {
+		_ = l.Next()
+	}
 
-func longestOperator(m map[string]token.TokenType) int {
-	result := ""
-	for s := range m {
-		if len(s) > len(result) {
-			result = s
+	if tok.Type == token.ILLEGAL {
+		expected := make([]string, 0, 4)
+		for i, op := range node.operators() {
+			if i == 3 {
+				expected = append(expected, "...")
+				break
+			}
+			expected = append(expected, strconv.Quote(op))
 		}
+		msg := fmt.Sprintf("incomplete operator %q, expected %s", l.slice(tok.Start.Pos, end), strings.Join(expected, " or "))
+		return l.EndRule(tok, Error{Lexer: l, Msg: msg, Pos: tok.Start, End: l.curPos})
 	}
-	return len(result)
+
+	return l.EndRule(tok, nil)
 }
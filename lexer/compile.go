@@ -0,0 +1,155 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/rdeusser/parsekit/internal/dfa"
+	"github.com/rdeusser/parsekit/token"
+)
+
+// Compile creates a Lexer whose rules, operators, and keywords are compiled into a single
+// minimized DFA, so that each token is lexed in one table-driven scan rather than by trying each
+// rule in turn. Every rule needs a Pattern and a Type, like the rules made by RegexpRule; their
+// Match and Action aren't used. The longest match wins, with ties going to keywords, then
+// operators, then rules by priority and the order they're listed in. Comments are lexed as usual.
+// Modes can't be compiled.
+func Compile(config Config, options ...Option) (*Lexer, error) {
+	if len(config.Modes) > 0 {
+		return nil, errors.New("lexer: configs with modes can't be compiled")
+	}
+
+	patterns := make([]string, 0, len(config.Keywords)+len(config.Operators)+len(config.Rules))
+	types := make([]token.TokenType, 0, cap(patterns))
+
+	for _, literals := range []map[string]token.TokenType{config.Keywords, config.Operators} {
+		sorted := make([]string, 0, len(literals))
+		for literal := range literals {
+			if literal != "" {
+				sorted = append(sorted, literal)
+			}
+		}
+		sort.Strings(sorted)
+
+		for _, literal := range sorted {
+			patterns = append(patterns, regexp.QuoteMeta(literal))
+			types = append(types, literals[literal])
+		}
+	}
+
+	// The DFA breaks ties by pattern order, so rules with higher priorities go first.
+	rules := append([]Rule(nil), config.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority > rules[j].Priority })
+
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("lexer: rule %q has no pattern to compile", rule.Name)
+		}
+		patterns = append(patterns, rule.Pattern)
+		types = append(types, rule.Type)
+	}
+
+	d, err := dfa.Compile(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("lexer: %w", err)
+	}
+
+	l := New(config, options...)
+
+	// Tokens are scanned directly, by scan, so the rules are only used to find where lexing can
+	// resume after an error.
+	m := l.modes[DefaultMode]
+	m.dfa = d
+	m.types = types
+	m.rules = make([]Rule, 0, 2)
+	if len(m.LineComments) > 0 || len(m.BlockComments) > 0 {
+		m.rules = append(m.rules, Rule{Name: "LexComment", Match: commentMatcher(m.Mode), Action: LexComment})
+		m.comment = &m.rules[0]
+	}
+	m.rules = append(m.rules, Rule{
+		Name: "DFA",
+		Match: func(ch rune) bool {
+			return !IsEOF(ch) && d.Next(d.Start(), ch) != dfa.Dead
+		},
+		Action: lexDFA(d, types),
+	})
+
+	return l, nil
+}
+
+// lexDFA returns an action that lexes the longest match of the DFA at the current position.
+func lexDFA(d *dfa.DFA, types []token.TokenType) Action {
+	return func(l *Lexer, ch rune) (token.Token, error) {
+		tok := l.StartRule(token.ILLEGAL)
+
+		end, pattern, _ := l.matchDFA(d)
+		if pattern < 0 {
+			return l.EndRule(tok, Error{Lexer: l, Msg: "no match", GotoNextRule: true})
+		}
+
+		tok.Type = types[pattern]
+		for l.curPos.Pos < end {
+			_ = l.Next()
+		}
+
+		return l.EndRule(tok, nil)
+	}
+}
+
+// scan lexes the next token in a compiled mode, starting with ch: a comment if one starts here,
+// or else the longest match of the DFA. The type of the token is the type of the pattern that
+// matched, so unlike lexDFA it isn't looked up again.
+func (l *Lexer) scan(ch rune) (token.Token, error) {
+	if l.mode.comment != nil && l.mode.comment.Match(ch) {
+		if tok, ok, err := l.runRule(*l.mode.comment, ch); ok {
+			return tok, err
+		}
+	}
+
+	start := l.curPos
+	end, pattern, plain := l.matchDFA(l.mode.dfa)
+	if pattern < 0 {
+		return token.NoToken, Error{Lexer: l, Msg: fmt.Sprintf("no rule to handle character %q", ch), Pos: start}
+	}
+
+	// Most tokens are plain ASCII on one line, with a column per byte in every encoding, so there's
+	// no need to step through them.
+	if plain {
+		l.prevPos = token.Position{Pos: end - 1, Line: start.Line, Column: start.Column + end - 1 - start.Pos}
+		l.curPos = token.Position{Pos: end, Line: start.Line, Column: start.Column + end - start.Pos}
+	}
+	for l.curPos.Pos < end {
+		_ = l.Next()
+	}
+	if l.encodingErr != nil {
+		return token.NoToken, l.encodingErr
+	}
+
+	return token.Token{Type: l.mode.types[pattern], Start: start, End: l.curPos, Literal: l.slice(start.Pos, end)}, nil
+}
+
+// matchDFA returns the end of the longest match of d at the current position and the pattern that
+// matched, or a pattern of -1 if nothing did. It also reports whether the match is plain: ASCII
+// without newlines.
+func (l *Lexer) matchDFA(d *dfa.DFA) (end, pattern int, plain bool) {
+	end, pattern = -1, -1
+	ascii := true
+	for state, pos := d.Start(), l.curPos.Pos; state != dfa.Dead; {
+		r, size := l.decode(pos)
+		if size == 0 {
+			break
+		}
+		ascii = ascii && r < utf8.RuneSelf && r != '\n'
+
+		state = d.Next(state, r)
+		pos += size
+
+		if p := d.Accept(state); p >= 0 {
+			end, pattern, plain = pos, p, ascii
+		}
+	}
+	return end, pattern, plain
+}
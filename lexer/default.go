@@ -6,11 +6,14 @@ import (
 
 var DefaultConfig = Config{
 	SkipWhitespace: true,
+	LineComments:   []string{"//"},
+	BlockComments:  []BlockComment{{Start: "/*", End: "*/"}},
 	Rules: []Rule{
 		{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
 		{Name: "LexString", Match: IsDoubleQuote, Action: LexString},
 		{Name: "LexRawString", Match: IsBackQuote, Action: LexRawString},
-		{Name: "LexNumber", Match: IsNumber, Action: LexNumber},
+		{Name: "LexChar", Match: IsSingleQuote, Action: LexChar},
+		{Name: "LexNumber", Match: IsNumberStart, Action: LexNumber},
 		{Name: "LexOperator", Match: IsOperator, Action: LexOperator},
 	},
 	Operators: map[string]token.TokenType{
@@ -54,7 +57,7 @@ var DefaultConfig = Config{
 		"(":   token.LPAREN,
 		"[":   token.LBRACK,
 		"{":   token.LBRACE,
-		"":    token.COMMA,
+		",":   token.COMMA,
 		".":   token.PERIOD,
 		")":   token.RPAREN,
 		"]":   token.RBRACK,
@@ -68,4 +71,22 @@ var DefaultConfig = Config{
 		"when":    token.WHEN,
 		"if":      token.IF,
 	},
+	Numbers: NumberConfig{
+		Hex:       true,
+		Octal:     true,
+		Binary:    true,
+		Float:     true,
+		Exponent:  true,
+		Separator: '_',
+	},
+}
+
+// Builtins are the rules for the built-in actions, keyed by the action's name.
+var Builtins = map[string]Rule{
+	"LexIdentifier": {Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+	"LexString":     {Name: "LexString", Match: IsDoubleQuote, Action: LexString},
+	"LexRawString":  {Name: "LexRawString", Match: IsBackQuote, Action: LexRawString},
+	"LexChar":       {Name: "LexChar", Match: IsSingleQuote, Action: LexChar},
+	"LexNumber":     {Name: "LexNumber", Match: IsNumberStart, Action: LexNumber},
+	"LexOperator":   {Name: "LexOperator", Match: IsOperator, Action: LexOperator},
 }
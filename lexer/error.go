@@ -2,11 +2,19 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/rdeusser/parsekit/diagnostic"
+	"github.com/rdeusser/parsekit/token"
 )
 
 type Error struct {
 	Lexer        *Lexer
+	Rule         string // name of the rule that caused the error, if any
 	Msg          string
+	Pos          token.Position // position of the error; defaults to the lexer's position
+	End          token.Position // end of the offending text, if known
+	File         *token.File    // file the error is in, if the lexer was lexing one
 	GotoNextRule bool
 }
 
@@ -17,5 +25,96 @@ func (e Error) Error() string {
 	if e.Msg == "" {
 		return "Msg cannot be empty"
 	}
-	return fmt.Sprintf("%s at %s", e.Msg, e.Lexer.curPos)
+	msg := e.Msg
+	if e.Rule != "" {
+		msg = fmt.Sprintf("%s: %s", e.Rule, e.Msg)
+	}
+	pos := e.Pos
+	if !pos.IsValid() {
+		pos = e.Lexer.curPos
+	}
+	if e.File != nil {
+		return fmt.Sprintf("%s at %s:%s", msg, e.File.Name(), pos)
+	}
+	return fmt.Sprintf("%s at %s", msg, pos)
+}
+
+// Diagnostic returns a diagnostic that underlines the offending text when rendered against the
+// input.
+func (e Error) Diagnostic() diagnostic.Diagnostic {
+	pos := e.Pos
+	if !pos.IsValid() && e.Lexer != nil {
+		pos = e.Lexer.curPos
+	}
+	msg := e.Msg
+	if e.Rule != "" {
+		msg = fmt.Sprintf("%s: %s", e.Rule, e.Msg)
+	}
+	return diagnostic.Diagnostic{
+		Msg:     msg,
+		Primary: diagnostic.Label{Start: pos, End: e.End},
+	}
+}
+
+// InputSizeError is returned when the input is larger than the limit set by WithMaxInputSize.
+type InputSizeError struct {
+	Max int
+}
+
+func (e InputSizeError) Error() string {
+	return fmt.Sprintf("input exceeds the limit of %d bytes", e.Max)
+}
+
+// TokenLimitError is returned when the input has more tokens than the limit set by WithMaxTokens.
+type TokenLimitError struct {
+	Max int
+	Pos token.Position // position of the first token over the limit
+}
+
+func (e TokenLimitError) Error() string {
+	return fmt.Sprintf("input exceeds the limit of %d tokens at %s", e.Max, e.Pos)
+}
+
+// ErrorList is a list of errors collected by a lexer in recovery mode.
+type ErrorList []Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	msgs := make([]string, 0, len(l))
+	for _, err := range l {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d errors:\n%s", len(l), strings.Join(msgs, "\n"))
+}
+
+// Diagnostics returns a diagnostic for each error in the list.
+func (l ErrorList) Diagnostics() []diagnostic.Diagnostic {
+	diags := make([]diagnostic.Diagnostic, 0, len(l))
+	for _, err := range l {
+		diags = append(diags, err.Diagnostic())
+	}
+	return diags
+}
+
+// Unwrap returns the errors in the list.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, 0, len(l))
+	for _, err := range l {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Err returns an error equivalent to the list, or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
 }
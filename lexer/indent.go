@@ -0,0 +1,143 @@
+package lexer
+
+import (
+	"io"
+	"strings"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+// Indentation configures indentation-sensitive lexing for languages like Python, where the
+// indentation of a line decides which block it belongs to. A token.NEWLINE token ends every line
+// with tokens on it. A line indented more than the one before it starts with a token.INDENT
+// token, and a line indented less starts with a token.DEDENT token for every level it closes.
+// Inside brackets lines carry on without any of these tokens. The inserted tokens have no text,
+// so they're zero-width.
+//
+// Indentation is compared as text: a line is indented more than another if its indentation starts
+// with the other's. Indentation that is neither more, less, nor the same, like a tab where a
+// space was used before, is an error. The lexer only sees indentation if it skips whitespace or
+// lexes losslessly.
+type Indentation struct {
+	Open  map[token.TokenType]bool // opening brackets, e.g. "(", "[", and "{"
+	Close map[token.TokenType]bool // closing brackets, e.g. ")", "]", and "}"
+}
+
+// layout is the state of indentation-sensitive lexing.
+type layout struct {
+	last    token.Token // the last token that wasn't whitespace or a comment
+	levels  []string    // the indentation of each open block, outermost first
+	depth   int         // how many brackets are open
+	pending []pending   // tokens waiting to be returned, from pending[next] on
+	next    int
+}
+
+// pending is a token to be returned along with its error.
+type pending struct {
+	tok token.Token
+	err error
+}
+
+// layoutToken returns the next token, inserting layout tokens before it as needed.
+func (l *Lexer) layoutToken() (token.Token, error) {
+	if l.layout.next < len(l.layout.pending) {
+		return l.dequeue()
+	}
+
+	// In recovery mode an error comes with the illegal token it's about, which takes its place in
+	// the layout like any other token.
+	var tok token.Token
+	err := l.nextToken(&tok)
+	if err != nil && err != io.EOF && !tok.Start.IsValid() || tok.Type == token.WHITESPACE || tok.Type == token.COMMENT {
+		return tok, err
+	}
+
+	newline, indent := l.newline, l.indent
+	l.newline = false
+
+	last := l.layout.last
+	l.layout.last = tok
+
+	switch {
+	case last.Type == token.EOF:
+		return tok, err
+	case !last.Start.IsValid():
+		// This is the first token.
+		l.layout.levels = append(l.layout.levels[:0], "")
+	case err == io.EOF || l.layout.depth == 0 && newline:
+		l.enqueue(token.NEWLINE, last.End, nil)
+	}
+
+	if err == io.EOF {
+		for len(l.layout.levels) > 1 {
+			l.layout.levels = l.layout.levels[:len(l.layout.levels)-1]
+			l.enqueue(token.DEDENT, tok.Start, nil)
+		}
+		l.layout.pending = append(l.layout.pending, pending{tok: tok, err: err})
+		return l.dequeue()
+	}
+
+	var indentErr error
+	if l.layout.depth == 0 && (newline || !last.Start.IsValid()) {
+		indentErr = l.indentTo(indent, tok)
+	}
+
+	switch {
+	case l.indentation.Open[tok.Type]:
+		l.layout.depth++
+	case l.indentation.Close[tok.Type] && l.layout.depth > 0:
+		l.layout.depth--
+	}
+
+	if indentErr != nil {
+		l.enqueue(token.ILLEGAL, tok.Start, indentErr)
+	}
+	l.layout.pending = append(l.layout.pending, pending{tok: tok, err: err})
+
+	return l.dequeue()
+}
+
+// indentTo opens or closes blocks so that the innermost one is indented by indent, the indentation
+// of the line starting with tok.
+func (l *Lexer) indentTo(indent string, tok token.Token) error {
+	current := l.layout.levels[len(l.layout.levels)-1]
+
+	switch {
+	case indent == current:
+		return nil
+	case strings.HasPrefix(indent, current):
+		l.layout.levels = append(l.layout.levels, indent)
+		l.enqueue(token.INDENT, tok.Start, nil)
+		return nil
+	case !strings.HasPrefix(current, indent):
+		l.layout.levels[len(l.layout.levels)-1] = indent
+		return Error{Lexer: l, Msg: "inconsistent use of tabs and spaces in indentation", Pos: tok.Start}
+	}
+
+	for len(l.layout.levels) > 1 && len(l.layout.levels[len(l.layout.levels)-1]) > len(indent) {
+		l.layout.levels = l.layout.levels[:len(l.layout.levels)-1]
+		l.enqueue(token.DEDENT, tok.Start, nil)
+	}
+
+	if current = l.layout.levels[len(l.layout.levels)-1]; current != indent {
+		l.layout.levels[len(l.layout.levels)-1] = indent
+		return Error{Lexer: l, Msg: "unindent does not match any outer indentation level", Pos: tok.Start}
+	}
+
+	return nil
+}
+
+// enqueue queues a zero-width token of type typ at pos, along with err.
+func (l *Lexer) enqueue(typ token.TokenType, pos token.Position, err error) {
+	l.layout.pending = append(l.layout.pending, pending{tok: token.Token{Type: typ, Start: pos, End: pos}, err: err})
+}
+
+// dequeue returns the next queued token.
+func (l *Lexer) dequeue() (token.Token, error) {
+	p := l.layout.pending[l.layout.next]
+	l.layout.next++
+	if l.layout.next == len(l.layout.pending) {
+		l.layout.pending, l.layout.next = l.layout.pending[:0], 0
+	}
+	return p.tok, p.err
+}
@@ -1,10 +1,12 @@
 package lexer
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync"
-	"time"
+	"io"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/rdeusser/parsekit"
 	"github.com/rdeusser/parsekit/internal/loopdetector"
@@ -15,13 +17,41 @@ var eof = rune(0)
 
 // Lexer is a generic lexer implementation.
 type Lexer struct {
-	input        string
+	input        string // the input, unless it's read from a stream
+	stream       bool   // whether the input is read from a stream into buf
+	buf          []byte // buffered input from a stream; buf[0] is at byte offset base
+	base         int
+	reader       io.Reader
+	readErr      error
 	curPos       token.Position
 	prevPos      token.Position
 	config       Config
+	modes        map[string]*mode
+	stack        []*mode
+	mode         *mode // the mode on top of the stack
 	logger       parsekit.Logger
+	debug        bool // whether logger writes debug messages
+	loopLimit    int
+	maxInputSize int
+	maxTokens    int
+	types        *token.Registry
+	count        int // how many tokens were returned for the input
+	lossless     bool
+	recover      bool
+	values       bool
+	longestMatch bool
+	longest      longest
+	tokStart     token.Position
 	loopDetector *loopdetector.Detector
-	mu           sync.Mutex
+	encodingErr  error
+	file         *token.File // the file being lexed, if any
+	encoding     token.Encoding
+	semicolons   *Semicolons
+	asi          asi
+	indentation  *Indentation
+	layout       layout
+	newline      bool   // whether a newline was lexed since the last token that wasn't whitespace or a comment
+	indent       string // the whitespace that starts the line of the next token
 }
 
 // Rule is a lexer rule with a name, matcher, and an action to take if that matcher matches
@@ -30,14 +60,38 @@ type Rule struct {
 	Name   string
 	Match  Matcher
 	Action Action
+
+	// Pattern and Type describe the tokens the rule produces as a regular expression and the type
+	// it's given. RegexpRule fills them in along with a Match and Action that implement them.
+	Pattern string
+	Type    token.TokenType
+
+	// Priority breaks ties between rules that match tokens of the same length when lexing with
+	// WithLongestMatch or a compiled lexer. Higher priorities win.
+	Priority int
 }
 
 // Config configures the lexer to respond to the provided rules and user-defined operators and keywords.
 type Config struct {
 	SkipWhitespace bool
+	SkipComments   bool
+	LineComments   []string       // prefixes of comments that run to the end of the line, e.g. "//" or "#"
+	BlockComments  []BlockComment // delimiters of comments that can span lines, e.g. "/*" and "*/"
 	Rules          []Rule
 	Operators      map[string]token.TokenType
 	Keywords       map[string]token.TokenType
+	Numbers        NumberConfig // numeric literals accepted by LexNumber
+
+	// Modes are additional named modes that actions can switch to with PushMode. The fields above
+	// make up the mode named DefaultMode, which is where lexing starts.
+	Modes map[string]Mode
+}
+
+// BlockComment describes the delimiters of a block comment.
+type BlockComment struct {
+	Start  string
+	End    string
+	Nested bool // whether block comments can contain other block comments
 }
 
 // Option sets options on lexers.
@@ -50,144 +104,596 @@ func WithLogger(logger parsekit.Logger) Option {
 	}
 }
 
+// WithLoopLimit sets how many consecutive rule actions may end without advancing the input
+// before lexing fails. The default is zero, meaning every action must consume input.
+func WithLoopLimit(limit int) Option {
+	return func(l *Lexer) {
+		l.loopLimit = limit
+	}
+}
+
+// WithMaxInputSize limits the input to max bytes. Lexing input that's larger fails with an
+// InputSizeError before the first token, or, when reading from a stream, as soon as the limit is
+// read past. The default is zero, meaning there's no limit.
+func WithMaxInputSize(max int) Option {
+	return func(l *Lexer) {
+		l.maxInputSize = max
+	}
+}
+
+// WithMaxTokens limits how many tokens the lexer returns for an input, not counting the EOF token.
+// Lexing more fails with a TokenLimitError. The default is zero, meaning there's no limit.
+func WithMaxTokens(max int) Option {
+	return func(l *Lexer) {
+		l.maxTokens = max
+	}
+}
+
+// WithTypes sets the registry that names the token types the lexer produces, for languages that
+// register them in their own registry. The default is token.Types.
+func WithTypes(types *token.Registry) Option {
+	return func(l *Lexer) {
+		l.types = types
+	}
+}
+
+// WithLossless makes the lexer return whitespace and comments as token.WHITESPACE and
+// token.COMMENT tokens regardless of Config.SkipWhitespace and Config.SkipComments. Every byte of
+// the input then belongs to exactly one token, so concatenating the literals of all tokens
+// reproduces the input.
+func WithLossless() Option {
+	return func(l *Lexer) {
+		l.lossless = true
+	}
+}
+
+// WithRecovery makes the lexer recover from errors instead of stopping at the first one. The text
+// that caused an error is returned as a token.ILLEGAL token and lexing resumes at the next
+// character a rule can match. Lex then returns every token along with an ErrorList.
+func WithRecovery() Option {
+	return func(l *Lexer) {
+		l.recover = true
+	}
+}
+
+// WithValues makes the lexer's built-in actions decode the values of string, character, and number
+// literals into token.Token.Value. Invalid escape sequences are reported either way.
+func WithValues() Option {
+	return func(l *Lexer) {
+		l.values = true
+	}
+}
+
+// WithSemicolons makes the lexer insert semicolons where a newline or the end of the input ends a
+// statement, as configured by s.
+func WithSemicolons(s Semicolons) Option {
+	return func(l *Lexer) {
+		l.semicolons = &s
+	}
+}
+
+// WithIndentation makes the lexer track indentation as configured by i, inserting token.NEWLINE
+// tokens at the ends of lines and token.INDENT and token.DEDENT tokens where the indentation
+// changes. It takes precedence over WithSemicolons.
+func WithIndentation(i Indentation) Option {
+	return func(l *Lexer) {
+		l.indentation = &i
+	}
+}
+
+// WithLongestMatch makes the lexer try every rule that matches the current character and keep the
+// longest token, rather than the first. Ties go to the rule with the highest Priority, then to
+// the one listed first. See Ambiguities for finding rules that tie.
+func WithLongestMatch() Option {
+	return func(l *Lexer) {
+		l.longestMatch = true
+	}
+}
+
+// WithEncoding makes the lexer count columns in enc rather than in runes, e.g. in token.UTF16 for
+// the Language Server Protocol.
+func WithEncoding(enc token.Encoding) Option {
+	return func(l *Lexer) {
+		l.encoding = enc
+	}
+}
+
 // New creates a new Lexer from a lexer config and options.
 func New(config Config, options ...Option) *Lexer {
 	lexer := &Lexer{
-		curPos: token.Position{
-			Line:   1,
-			Column: 1,
-		},
-		config:       config,
-		logger:       parsekit.DefaultLogger,
-		loopDetector: loopdetector.New(),
-		mu:           sync.Mutex{},
+		config:    config,
+		modes:     modes(config),
+		logger:    parsekit.DefaultLogger,
+		loopLimit: loopdetector.DefaultLimit,
+		types:     token.Types,
 	}
 
 	for _, option := range options {
 		option(lexer)
 	}
 
+	lexer.debug = parsekit.DebugEnabled(lexer.logger)
+	lexer.loopDetector = loopdetector.New(lexer.loopLimit)
+	lexer.reset("", nil)
+
 	return lexer
 }
 
-// Lex lexes the input and returns a slice of tokens, or an error.
+// Clone returns a new Lexer with the same config and options as l but none of its state. The
+// config and the tables built from it are shared rather than copied, so clones are cheap to make
+// and can lex concurrently, one per goroutine.
+func (l *Lexer) Clone() *Lexer {
+	lexer := &Lexer{
+		config:       l.config,
+		modes:        l.modes,
+		logger:       l.logger,
+		debug:        l.debug,
+		loopLimit:    l.loopLimit,
+		maxInputSize: l.maxInputSize,
+		maxTokens:    l.maxTokens,
+		types:        l.types,
+		lossless:     l.lossless,
+		recover:      l.recover,
+		values:       l.values,
+		longestMatch: l.longestMatch,
+		semicolons:   l.semicolons,
+		indentation:  l.indentation,
+		encoding:     l.encoding,
+		loopDetector: loopdetector.New(l.loopLimit),
+	}
+
+	lexer.reset("", nil)
+
+	return lexer
+}
+
+// Types returns the registry that names the token types the lexer produces.
+func (l *Lexer) Types() *token.Registry {
+	return l.types
+}
+
+// Reset discards the lexer's state and prepares it to lex input from the beginning.
+func (l *Lexer) Reset(input string) {
+	l.file = nil
+	l.reset(input, nil)
+}
+
+// ResetFile is like Reset, but input is the content of file. The file's tables are built as the
+// input is lexed, so that the offsets of the tokens resolve to the same positions in the file, and
+// errors carry the file's name. It panics if the file's size isn't the size of input.
+func (l *Lexer) ResetFile(file *token.File, input string) {
+	if file.Size() != len(input) {
+		panic(fmt.Sprintf("file %s has size %d, but the input has %d bytes", file.Name(), file.Size(), len(input)))
+	}
+	l.file = file
+	l.reset(input, nil)
+}
+
+func (l *Lexer) reset(input string, r io.Reader) {
+	l.input = input
+	l.stream = r != nil
+	l.buf = l.buf[:0]
+	l.base = 0
+	l.reader = r
+	l.readErr = nil
+	if l.maxInputSize > 0 && len(input) > l.maxInputSize {
+		l.readErr = InputSizeError{Max: l.maxInputSize}
+	}
+	l.encodingErr = nil
+	l.count = 0
+	l.curPos = token.Position{Line: 1, Column: 1}
+	l.prevPos = token.Position{}
+	l.mode = l.modes[DefaultMode]
+	l.stack = append(l.stack[:0], l.mode)
+	l.loopDetector.Reset()
+	l.asi = asi{}
+	l.layout = layout{}
+	l.newline = false
+	l.indent = ""
+}
+
+// bytesPerToken is a rough guess at the average size of a token in source code, counting the
+// whitespace around it, used to preallocate token slices from the size of the input.
+const bytesPerToken = 4
+
+// maxPrealloc is the most tokens preallocated up front. Slices grow past it as usual, so a large
+// input only costs memory in proportion to the tokens actually lexed.
+const maxPrealloc = 64 * 1024
+
+// prealloc returns how many tokens to preallocate for an input of size bytes: none if the input
+// is over the size limit, and no more than the token limit allows.
+func (l *Lexer) prealloc(size int) int {
+	if l.readErr != nil {
+		return 0
+	}
+	n := min(size/bytesPerToken, maxPrealloc)
+	if l.maxTokens > 0 {
+		n = min(n, l.maxTokens+1)
+	}
+	return n
+}
+
+// Lex lexes the input from the beginning and returns a slice of tokens, or an error. In recovery
+// mode the tokens are returned even if there were errors, and the error is an ErrorList.
 func (l *Lexer) Lex(input string) ([]token.Token, error) {
-	go func() {
-		ticker := time.NewTicker(500 * time.Millisecond)
-		for {
-			<-ticker.C
-			l.loopDetector.Detect(&l.mu, l.curPos.Pos)
-			if l.loopDetector.IsLooping() {
-				panic(fmt.Sprintf("lexer error: detected an infinite loop: %s", l.curPos))
-			}
-			l.logger.Debug("No loop detected")
+	return l.LexContext(context.Background(), input)
+}
+
+// LexContext is like Lex, but stops with ctx's error if ctx is done before lexing finishes.
+func (l *Lexer) LexContext(ctx context.Context, input string) ([]token.Token, error) {
+	l.Reset(input)
+	return l.lexTokens(ctx, len(input))
+}
+
+// LexFile is like Lex, but input is the content of file. See ResetFile.
+func (l *Lexer) LexFile(file *token.File, input string) ([]token.Token, error) {
+	l.ResetFile(file, input)
+	return l.lexTokens(context.Background(), len(input))
+}
+
+// LexSpans is like Lex, but returns the tokens in their compact form, which takes up a fraction of
+// the memory. Their literals and positions can be resolved when needed; see token.Span.
+func (l *Lexer) LexSpans(input string) ([]token.Span, error) {
+	l.Reset(input)
+
+	spans := make([]token.Span, 0, l.prealloc(len(input)))
+	err := l.lex(context.Background(), func(tok *token.Token) {
+		spans = append(spans, token.Span{Type: tok.Type, Offset: tok.Start.Pos, Len: tok.End.Pos - tok.Start.Pos})
+	})
+	if _, ok := err.(ErrorList); err != nil && !ok {
+		return nil, err
+	}
+
+	return spans, err
+}
+
+func (l *Lexer) lexTokens(ctx context.Context, size int) ([]token.Token, error) {
+	tokens := make([]token.Token, 0, l.prealloc(size))
+	err := l.lex(ctx, func(tok *token.Token) {
+		tokens = append(tokens, *tok)
+	})
+	if _, ok := err.(ErrorList); err != nil && !ok {
+		return nil, err
+	}
+
+	return tokens, err
+}
+
+// lex lexes the rest of the input, passing each token to emit. In recovery mode the errors are
+// returned together as an ErrorList. The token passed to emit is only valid until emit returns.
+func (l *Lexer) lex(ctx context.Context, emit func(*token.Token)) error {
+	var errs ErrorList
+	var tok token.Token
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
 		}
-	}()
 
-	l.input = input
-	tokens := make([]token.Token, 0)
-	for l.curPos.Pos < len(l.input) {
-		matched := false
-		ch := l.currentChar()
-
-		if l.config.SkipWhitespace {
-			for IsWhitespace(ch) {
-				ch = l.Next()
+		err := l.next(&tok)
+		if err == io.EOF {
+			return errs.Err()
+		}
+		if err != nil {
+			var lerr Error
+			if !l.recover || !errors.As(err, &lerr) {
+				return err
 			}
+			errs = append(errs, lerr)
 		}
+		emit(&tok)
+	}
+}
 
-		if IsEOF(ch) {
-			break
+// NextToken lexes the next token from the input. At the end of the input it returns a token of
+// type token.EOF along with io.EOF. In recovery mode a lexing error is returned along with the
+// token.ILLEGAL token covering the offending text, and the next call picks up after it.
+func (l *Lexer) NextToken() (token.Token, error) {
+	var tok token.Token
+	err := l.next(&tok)
+	return tok, err
+}
+
+// next is NextToken, but writes the token to tok. Tokens are large, and lexing a whole input
+// copies each of them through here, so they're passed by pointer rather than returned.
+func (l *Lexer) next(tok *token.Token) error {
+	var err error
+	switch {
+	case l.indentation != nil:
+		*tok, err = l.layoutToken()
+	case l.semicolons != nil:
+		err = l.insertSemicolon(tok)
+	default:
+		err = l.nextToken(tok)
+	}
+
+	if err != io.EOF {
+		l.count++
+		if l.maxTokens > 0 && l.count > l.maxTokens {
+			pos := tok.Start
+			*tok = token.NoToken
+			return TokenLimitError{Max: l.maxTokens, Pos: pos}
 		}
+	}
 
-		for _, rule := range l.config.Rules {
-			l.logger.Debug("Attempting to match %q with char %q", rule.Name, ch)
+	if lerr, ok := err.(Error); ok && l.file != nil {
+		lerr.File = l.file
+		err = lerr
+	}
 
-			if rule.Match(ch) {
-				l.logger.Debug("Running action %q", rule.Name)
-
-				tok, err := rule.Action(l, ch)
-				var lerr Error
-				if errors.As(err, &lerr) {
-					if lerr.GotoNextRule {
-						l.logger.Debug("Received an error from %q, moving to next rule", rule.Name)
-						if l.prevPos.IsValid() {
-							_ = l.Prev()
-						}
-						continue
-					} else {
-						_ = lerr.Error()
-						return nil, lerr
-					}
-				} else if err != nil {
-					return nil, err
-				}
+	return err
+}
 
-				if !tok.Start.IsValid() || !tok.End.IsValid() {
-					return nil, fmt.Errorf("lexer error: start and/or end position is invalid (did you forget to start or end the rule?)")
-				}
+func (l *Lexer) nextToken(tok *token.Token) error {
+	for {
+		var err error
+		*tok, err = l.lexToken()
+		if err == nil && tok.Type == token.COMMENT {
+			if strings.Contains(tok.Literal, "\n") {
+				l.newline = true
+			}
+			if l.mode.SkipComments && !l.lossless {
+				continue
+			}
+		}
 
-				if tok.Type == token.ILLEGAL {
-					return nil, fmt.Errorf("lexer error: illegal token: %s: %q", tok, l.input[tok.Start.Pos:tok.End.Pos])
+		if err != nil && l.recover {
+			var lerr Error
+			if errors.As(err, &lerr) {
+				*tok = l.resync()
+				if !lerr.End.IsValid() {
+					lerr.End = tok.End
 				}
+				return lerr
+			}
+		}
+
+		return err
+	}
+}
+
+// resync skips from the start of the token that failed to lex to the next character a rule can
+// match and returns the skipped text as an illegal token.
+func (l *Lexer) resync() token.Token {
+	tok := l.StartRule(token.ILLEGAL)
+	tok.Start = l.tokStart
+
+	if l.curPos.Pos <= tok.Start.Pos {
+		l.curPos = tok.Start
+		_ = l.Next()
+	}
+
+	for ch := l.currentChar(); !IsEOF(ch) && (l.invalid(l.curPos.Pos) || !l.canMatch(ch)); {
+		ch = l.Next()
+	}
+
+	// The skipped text is reported as a whole, so any encoding errors in it were already covered.
+	l.encodingErr = nil
+	l.loopDetector.Reset()
+
+	tok.End = l.curPos
+	tok.Literal = l.slice(tok.Start.Pos, tok.End.Pos)
+
+	return tok
+}
+
+// skipWhitespace skips whitespace, noting whether it has a newline in it and the indentation of
+// the line that follows it.
+func (l *Lexer) skipWhitespace(ch rune) rune {
+	lineStart := -1
+	if l.curPos.Pos == 0 {
+		lineStart = 0
+	}
+
+	for IsWhitespace(ch) {
+		if ch == '\n' {
+			l.newline = true
+			lineStart = l.curPos.Pos + 1
+		}
+		ch = l.Next()
+	}
+
+	if lineStart >= 0 {
+		l.indent = l.slice(lineStart, l.curPos.Pos)
+	}
+
+	return ch
+}
+
+// canMatch reports whether lexing can resume at ch.
+func (l *Lexer) canMatch(ch rune) bool {
+	if IsWhitespace(ch) && (l.mode.SkipWhitespace || l.lossless) {
+		return true
+	}
+	for _, rule := range l.mode.rules {
+		if rule.Match(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Lexer) lexToken() (token.Token, error) {
+	l.discard()
+
+	ch := l.currentChar()
+
+	if l.lossless && IsWhitespace(ch) {
+		tok := l.StartRule(token.WHITESPACE)
+		l.skipWhitespace(ch)
+		return l.EndRule(tok, nil)
+	}
+
+	if l.mode.SkipWhitespace {
+		ch = l.skipWhitespace(ch)
+	}
+
+	l.tokStart = l.curPos
+
+	if l.encodingErr != nil {
+		return token.NoToken, l.encodingErr
+	}
+
+	if l.readErr != nil {
+		return token.NoToken, l.readErr
+	}
+
+	if l.invalid(l.curPos.Pos) {
+		return token.NoToken, Error{Lexer: l, Msg: "invalid UTF-8 encoding", Pos: l.curPos}
+	}
+
+	if IsEOF(ch) {
+		return token.Token{Type: token.EOF, Start: l.curPos, End: l.curPos}, io.EOF
+	}
 
-				tokens = append(tokens, tok)
-				matched = true
-				break
+	if l.mode.dfa != nil {
+		return l.scan(ch)
+	}
+
+	if l.longestMatch {
+		return l.lexLongest(ch)
+	}
+
+	for _, rule := range l.mode.rules {
+		if l.debug {
+			l.logger.Debug("Attempting to match %q with char %q", rule.Name, ch)
+		}
+
+		if rule.Match(ch) {
+			l.loopDetector.Mark(l.curPos.Pos)
+			tok, ok, err := l.runRule(rule, ch)
+			if !ok {
+				continue
 			}
+			if err != nil {
+				return token.NoToken, err
+			}
+			return l.detectLoop(rule, tok)
 		}
+	}
+
+	return token.NoToken, Error{Lexer: l, Msg: fmt.Sprintf("no rule to handle character %q", ch), Pos: l.curPos}
+}
 
-		if !matched {
-			// TODO(rdeusser): add output with line numbers and an up arrow at position.
-			return nil, fmt.Errorf("lexer error: no rule to handle character at %s", l.curPos)
+// runRule runs the action of rule at the current position. It reports false if the action moved
+// on to the next rule, in which case the position is restored.
+func (l *Lexer) runRule(rule Rule, ch rune) (token.Token, bool, error) {
+	if l.debug {
+		l.logger.Debug("Running action %q", rule.Name)
+	}
+
+	start, prev, encodingErr := l.curPos, l.prevPos, l.encodingErr
+
+	tok, err := rule.Action(l, ch)
+	if err != nil {
+		// Actions usually return an Error as is, which can be had without errors.As, whose
+		// argument escapes.
+		lerr, ok := err.(Error)
+		if !ok {
+			var wrapped Error
+			if !errors.As(err, &wrapped) {
+				return token.NoToken, true, Error{Lexer: l, Rule: rule.Name, Msg: err.Error(), Pos: start}
+			}
+			lerr = wrapped
 		}
+		if lerr.GotoNextRule {
+			if l.debug {
+				l.logger.Debug("Received an error from %q, moving to next rule", rule.Name)
+			}
+			l.curPos, l.prevPos, l.encodingErr = start, prev, encodingErr
+			return token.NoToken, false, nil
+		}
+		if lerr.Rule == "" {
+			lerr.Rule = rule.Name
+		}
+		if !lerr.Pos.IsValid() {
+			lerr.Pos = l.curPos
+		}
+		return token.NoToken, true, lerr
+	}
+
+	if l.encodingErr != nil {
+		return token.NoToken, true, l.encodingErr
+	}
+
+	if !tok.Start.IsValid() || !tok.End.IsValid() {
+		return token.NoToken, true, Error{Lexer: l, Rule: rule.Name, Msg: "start and/or end position is invalid (did you forget to start or end the rule?)", Pos: start}
+	}
+
+	if tok.Type == token.ILLEGAL {
+		return token.NoToken, true, Error{Lexer: l, Rule: rule.Name, Msg: fmt.Sprintf("illegal token %q", tok.Literal), Pos: tok.Start}
 	}
 
-	return tokens, nil
+	return tok, true, nil
 }
 
-// Lookahead how many runes ahead.
+// detectLoop returns tok, or an error if lexing it didn't advance the input too many times in a
+// row.
+func (l *Lexer) detectLoop(rule Rule, tok token.Token) (token.Token, error) {
+	l.loopDetector.Detect(l.curPos.Pos)
+	if l.loopDetector.IsLooping() {
+		return token.NoToken, Error{Lexer: l, Rule: rule.Name, Msg: "detected an infinite loop: rule didn't advance the input", Pos: tok.Start}
+	}
+	return tok, nil
+}
+
+// Lookahead returns up to n runes starting at the current position without consuming them.
 func (l *Lexer) Lookahead(n int) []rune {
 	if n < 0 {
 		return nil
 	}
-	if l.curPos.Pos+n >= len(l.input) {
-		return []rune(l.input[l.curPos.Pos:])
+	runes := make([]rune, 0, n)
+	for pos := l.curPos.Pos; len(runes) < n; {
+		ch, size := l.decode(pos)
+		if size == 0 {
+			break
+		}
+		runes = append(runes, ch)
+		pos += size
 	}
-	return []rune(l.input[l.curPos.Pos : l.curPos.Pos+n])
+	return runes
 }
 
+// Next advances the lexer by one rune and returns the new current rune. Pos advances by the
+// rune's width in bytes and Column by its width in the lexer's encoding, unless the rune was a
+// newline, which starts a new line. Advancing past an invalid UTF-8 encoding fails the token being
+// lexed; looking at one doesn't.
 func (l *Lexer) Next() rune {
-	if l.curPos.Pos < len(l.input) {
-		l.prevPos = l.curPos
+	prevCh, size := l.decode(l.curPos.Pos)
+	if size == 0 {
+		return eof
 	}
 
-	if l.curPos.Line == 0 {
-		l.curPos.Line = 1
+	if prevCh == utf8.RuneError && size == 1 && l.encodingErr == nil {
+		l.encodingErr = Error{Lexer: l, Msg: "invalid UTF-8 encoding", Pos: l.curPos}
 	}
 
-	ch := l.currentChar()
-	if l.curPos.Pos+1 >= len(l.input) {
-		ch = eof
-	} else {
-		ch = rune(l.input[l.curPos.Pos+1])
-	}
-
-	l.curPos.Pos++
-	l.curPos.Column++
+	l.prevPos = l.curPos
 
-	if rune(l.input[l.prevPos.Pos]) != '\\' && rune(l.input[l.prevPos.Pos]) != '\'' {
-		if ch == '\n' || ch == '\r' {
-			l.curPos.Column = 1
-			l.curPos.Line++
+	l.curPos.Pos += size
+	if prevCh == '\n' {
+		l.curPos.Line++
+		l.curPos.Column = 1
+		if l.file != nil {
+			l.file.AddLine(l.curPos.Pos)
+		}
+	} else {
+		width := l.encoding.Width(prevCh, size)
+		l.curPos.Column += width
+		if l.file != nil && width < size {
+			l.file.AddChar(l.prevPos.Pos, size, width)
 		}
 	}
 
-	return ch
+	return l.currentChar()
 }
 
 func (l *Lexer) Prev() rune {
 	l.curPos = l.prevPos
+	if lerr, ok := l.encodingErr.(Error); ok && lerr.Pos.Pos >= l.curPos.Pos {
+		l.encodingErr = nil
+	}
 	return l.currentChar()
 }
 
@@ -202,11 +708,7 @@ func (l *Lexer) StartRule(tokenType token.TokenType) token.Token {
 // EndRule is a helper method for ending a lex rule.
 func (l *Lexer) EndRule(tok token.Token, err error) (token.Token, error) {
 	tok.End = l.currentPos()
-	if tok.End.Pos >= len(l.input) {
-		tok.Literal = l.input[tok.Start.Pos:len(l.input)]
-	} else {
-		tok.Literal = l.input[tok.Start.Pos:tok.End.Pos]
-	}
+	tok.Literal = l.slice(tok.Start.Pos, tok.End.Pos)
 	// If the user already set the type, we shouldn't try to look it up because we can't look up things like strings. Only operators and keywords.
 	if typ := l.LookupToken(tok.Literal); typ != token.ILLEGAL {
 		tok.Type = typ
@@ -215,21 +717,96 @@ func (l *Lexer) EndRule(tok token.Token, err error) (token.Token, error) {
 }
 
 func (l *Lexer) LookupToken(literal string) token.TokenType {
-	if t, ok := l.config.Operators[literal]; ok {
+	if t, ok := l.mode.Operators[literal]; ok {
 		return t
 	}
-	if t, ok := l.config.Keywords[literal]; ok {
+	if t, ok := l.mode.Keywords[literal]; ok {
 		return t
 	}
 	return token.ILLEGAL
 }
 
+// hasPrefix reports whether the input at the current position starts with prefix.
+func (l *Lexer) hasPrefix(prefix string) bool {
+	pos := l.curPos.Pos
+	l.fill(pos, len(prefix))
+	if l.stream {
+		// The conversion is only compared, so it doesn't copy the buffer.
+		i := pos - l.base
+		return prefix != "" && i+len(prefix) <= len(l.buf) && string(l.buf[i:i+len(prefix)]) == prefix
+	}
+	return prefix != "" && strings.HasPrefix(l.input[pos:], prefix)
+}
+
+// peek returns the rune after the current one without consuming anything.
+func (l *Lexer) peek() rune {
+	_, size := l.decode(l.curPos.Pos)
+	ch, _ := l.decode(l.curPos.Pos + size)
+	return ch
+}
+
+// skip advances past prefix, which must be at the current position.
+func (l *Lexer) skip(prefix string) rune {
+	ch := l.currentChar()
+	for end := l.curPos.Pos + len(prefix); l.curPos.Pos < end; {
+		ch = l.Next()
+	}
+	return ch
+}
+
+// charEnd returns the position after the current character.
+func (l *Lexer) charEnd() token.Position {
+	ch, size := l.decode(l.curPos.Pos)
+	pos := l.curPos
+	pos.Pos += size
+	pos.Column += l.encoding.Width(ch, size)
+	return pos
+}
+
 func (l *Lexer) currentChar() rune {
-	if l.curPos.Pos >= len(l.input) {
-		return eof
-	} else {
-		return rune(l.input[l.curPos.Pos])
+	ch, _ := l.decode(l.curPos.Pos)
+	return ch
+}
+
+// invalid reports whether the input at the byte offset pos isn't valid UTF-8.
+func (l *Lexer) invalid(pos int) bool {
+	ch, size := l.decode(pos)
+	return ch == utf8.RuneError && size == 1
+}
+
+// decode decodes the rune starting at the byte offset pos and returns it along with its width
+// in bytes. Invalid encodings decode as utf8.RuneError with a width of 1.
+func (l *Lexer) decode(pos int) (rune, int) {
+	if l.stream {
+		l.fill(pos, utf8.UTFMax)
+		i := pos - l.base
+		if i >= len(l.buf) {
+			return eof, 0
+		}
+		if b := l.buf[i]; b < utf8.RuneSelf {
+			return rune(b), 1
+		}
+		return utf8.DecodeRune(l.buf[i:])
+	}
+
+	if pos >= len(l.input) {
+		return eof, 0
+	}
+	if b := l.input[pos]; b < utf8.RuneSelf {
+		return rune(b), 1
+	}
+	return utf8.DecodeRuneInString(l.input[pos:])
+}
+
+// slice returns the input between the byte offsets start and end, which must be buffered if it's
+// read from a stream. The buffer is reused, so text from it is copied.
+func (l *Lexer) slice(start, end int) string {
+	if l.stream {
+		start -= l.base
+		end = min(end-l.base, len(l.buf))
+		return string(l.buf[start:end])
 	}
+	return l.input[start:min(end, len(l.input))]
 }
 
 func (l *Lexer) currentPos() token.Position {
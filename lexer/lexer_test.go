@@ -1,11 +1,20 @@
 package lexer
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 
 	"github.com/hexops/autogold/v2"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/rdeusser/parsekit/diagnostic"
 	"github.com/rdeusser/parsekit/token"
 )
 
@@ -40,6 +49,363 @@ func TestLexer(t *testing.T) {
 				},
 			}),
 		},
+		"unicode identifier": {
+			"héllo wörld",
+			Config{
+				SkipWhitespace: true,
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+				},
+			},
+			assert.NoError,
+			autogold.Expect([]token.Token{
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Line:   1,
+						Column: 1,
+					},
+					End: token.Position{
+						Pos:    6,
+						Line:   1,
+						Column: 6,
+					},
+					Literal: "héllo",
+				},
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Pos:    7,
+						Line:   1,
+						Column: 7,
+					},
+					End: token.Position{
+						Pos:    13,
+						Line:   1,
+						Column: 12,
+					},
+					Literal: "wörld",
+				},
+			}),
+		},
+		"unicode string": {
+			"\"naïve 🎉\"",
+			Config{
+				Rules: []Rule{
+					{Name: "LexString", Match: IsDoubleQuote, Action: LexString},
+				},
+			},
+			assert.NoError,
+			autogold.Expect([]token.Token{{
+				Type: token.TokenType(5),
+				Start: token.Position{
+					Line:   1,
+					Column: 1,
+				},
+				End: token.Position{
+					Pos:    13,
+					Line:   1,
+					Column: 10,
+				},
+				Literal: `"naïve 🎉"`,
+			}}),
+		},
+		"invalid utf-8": {
+			"ab\xffc",
+			Config{
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+				},
+			},
+			assert.Error,
+			autogold.Expect([]token.Token{}),
+		},
+		"unterminated string": {
+			"\"foo",
+			Config{
+				Rules: []Rule{
+					{Name: "LexString", Match: IsDoubleQuote, Action: LexString},
+				},
+			},
+			assert.Error,
+			autogold.Expect([]token.Token{}),
+		},
+		"line comment": {
+			"a / b // c\n# d",
+			Config{
+				SkipWhitespace: true,
+				LineComments:   []string{"//", "#"},
+				BlockComments:  []BlockComment{{Start: "/*", End: "*/", Nested: true}},
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+					{Name: "LexOperator", Match: IsOperator, Action: LexOperator},
+				},
+				Operators: map[string]token.TokenType{
+					"/": 2001,
+				},
+			},
+			assert.NoError,
+			autogold.Expect([]token.Token{
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Line:   1,
+						Column: 1,
+					},
+					End: token.Position{
+						Pos:    1,
+						Line:   1,
+						Column: 2,
+					},
+					Literal: "a",
+				},
+				{
+					Type: token.TokenType(2001),
+					Start: token.Position{
+						Pos:    2,
+						Line:   1,
+						Column: 3,
+					},
+					End: token.Position{
+						Pos:    3,
+						Line:   1,
+						Column: 4,
+					},
+					Literal: "/",
+				},
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Pos:    4,
+						Line:   1,
+						Column: 5,
+					},
+					End: token.Position{
+						Pos:    5,
+						Line:   1,
+						Column: 6,
+					},
+					Literal: "b",
+				},
+				{
+					Type: token.TokenType(2),
+					Start: token.Position{
+						Pos:    6,
+						Line:   1,
+						Column: 7,
+					},
+					End: token.Position{
+						Pos:    10,
+						Line:   1,
+						Column: 11,
+					},
+					Literal: "// c",
+				},
+				{
+					Type: token.TokenType(2),
+					Start: token.Position{
+						Pos:    11,
+						Line:   2,
+						Column: 1,
+					},
+					End: token.Position{
+						Pos:    14,
+						Line:   2,
+						Column: 4,
+					},
+					Literal: "# d",
+				},
+			}),
+		},
+		"nested block comment": {
+			"a /* b /* c */ d */ e",
+			Config{
+				SkipWhitespace: true,
+				LineComments:   []string{"//", "#"},
+				BlockComments:  []BlockComment{{Start: "/*", End: "*/", Nested: true}},
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+					{Name: "LexOperator", Match: IsOperator, Action: LexOperator},
+				},
+				Operators: map[string]token.TokenType{
+					"/": 2001,
+				},
+			},
+			assert.NoError,
+			autogold.Expect([]token.Token{
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Line:   1,
+						Column: 1,
+					},
+					End: token.Position{
+						Pos:    1,
+						Line:   1,
+						Column: 2,
+					},
+					Literal: "a",
+				},
+				{
+					Type: token.TokenType(2),
+					Start: token.Position{
+						Pos:    2,
+						Line:   1,
+						Column: 3,
+					},
+					End: token.Position{
+						Pos:    19,
+						Line:   1,
+						Column: 20,
+					},
+					Literal: "/* b /* c */ d */",
+				},
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Pos:    20,
+						Line:   1,
+						Column: 21,
+					},
+					End: token.Position{
+						Pos:    21,
+						Line:   1,
+						Column: 22,
+					},
+					Literal: "e",
+				},
+			}),
+		},
+		"unterminated block comment": {
+			"a /* b /* c */ d",
+			Config{
+				SkipWhitespace: true,
+				LineComments:   []string{"//", "#"},
+				BlockComments:  []BlockComment{{Start: "/*", End: "*/", Nested: true}},
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+					{Name: "LexOperator", Match: IsOperator, Action: LexOperator},
+				},
+				Operators: map[string]token.TokenType{
+					"/": 2001,
+				},
+			},
+			assert.Error,
+			autogold.Expect([]token.Token{}),
+		},
+		"skipped comment": {
+			"a /* b */ c",
+			Config{
+				SkipWhitespace: true,
+				SkipComments:   true,
+				BlockComments:  []BlockComment{{Start: "/*", End: "*/"}},
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+				},
+			},
+			assert.NoError,
+			autogold.Expect([]token.Token{
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Line:   1,
+						Column: 1,
+					},
+					End: token.Position{
+						Pos:    1,
+						Line:   1,
+						Column: 2,
+					},
+					Literal: "a",
+				},
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Pos:    10,
+						Line:   1,
+						Column: 11,
+					},
+					End: token.Position{
+						Pos:    11,
+						Line:   1,
+						Column: 12,
+					},
+					Literal: "c",
+				},
+			}),
+		},
+		"regexp rules": {
+			"0x1F_ff if foo 12",
+			Config{
+				SkipWhitespace: true,
+				Rules: []Rule{
+					MustRegexpRule("Hex", `0[xX][0-9a-fA-F_]+`, 1001),
+					MustRegexpRule("Ident", `\pL\w*`, token.IDENT),
+					MustRegexpRule("Int", `[0-9]+`, token.NUMBER),
+				},
+				Keywords: map[string]token.TokenType{
+					"if": token.IF,
+				},
+			},
+			assert.NoError,
+			autogold.Expect([]token.Token{
+				{
+					Type: token.TokenType(1001),
+					Start: token.Position{
+						Line:   1,
+						Column: 1,
+					},
+					End: token.Position{
+						Pos:    7,
+						Line:   1,
+						Column: 8,
+					},
+					Literal: "0x1F_ff",
+				},
+				{
+					Type: token.TokenType(59),
+					Start: token.Position{
+						Pos:    8,
+						Line:   1,
+						Column: 9,
+					},
+					End: token.Position{
+						Pos:    10,
+						Line:   1,
+						Column: 11,
+					},
+					Literal: "if",
+				},
+				{
+					Type: token.TokenType(4),
+					Start: token.Position{
+						Pos:    11,
+						Line:   1,
+						Column: 12,
+					},
+					End: token.Position{
+						Pos:    14,
+						Line:   1,
+						Column: 15,
+					},
+					Literal: "foo",
+				},
+				{
+					Type: token.TokenType(7),
+					Start: token.Position{
+						Pos:    15,
+						Line:   1,
+						Column: 16,
+					},
+					End: token.Position{
+						Pos:    17,
+						Line:   1,
+						Column: 18,
+					},
+					Literal: "12",
+				},
+			}),
+		},
 		"char": {
 			"'f'",
 			Config{
@@ -65,7 +431,7 @@ func TestLexer(t *testing.T) {
 			}),
 		},
 		"newline char": {
-			"'\n'",
+			`'\n'`,
 			Config{
 				Rules: []Rule{
 					{Name: "LexChar", Match: IsSingleQuote, Action: LexChar},
@@ -80,11 +446,11 @@ func TestLexer(t *testing.T) {
 						Column: 1,
 					},
 					End: token.Position{
-						Pos:    3,
+						Pos:    4,
 						Line:   1,
-						Column: 4,
+						Column: 5,
 					},
-					Literal: "'\n'",
+					Literal: "'\\n'",
 				},
 			}),
 		},
@@ -174,7 +540,7 @@ func TestLexer(t *testing.T) {
 					End: token.Position{
 						Pos:    13,
 						Line:   2,
-						Column: 8,
+						Column: 7,
 					},
 					Literal: "`hello\nworld`",
 				},
@@ -264,3 +630,807 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestNextToken(t *testing.T) {
+	input := "package main\n\nfunc héllo() string {\n\treturn \"wörld 🎉\" + `raw\nstring`\n}\n"
+
+	want, err := New(DefaultConfig).Lex(input)
+	assert.NoError(t, err)
+
+	l := New(DefaultConfig)
+	l.Init(iotest.OneByteReader(strings.NewReader(input)))
+
+	got := make([]token.Token, 0)
+	for {
+		tok, err := l.NextToken()
+		if err == io.EOF {
+			assert.Equal(t, token.EOF, tok.Type)
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		got = append(got, tok)
+	}
+
+	assert.Equal(t, want, got)
+
+	// The buffer is reused as the input is read, which mustn't change the literals of earlier
+	// tokens, and grows to hold a token of any size.
+	input = strings.Repeat("foo + `bar`\n", readSize/4) + "`" + strings.Repeat("x", 4*readSize) + "` baz"
+
+	want, err = New(DefaultConfig).Lex(input)
+	assert.NoError(t, err)
+
+	l.Init(strings.NewReader(input))
+	got = got[:0]
+	for {
+		tok, err := l.NextToken()
+		if err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			break
+		}
+		got = append(got, tok)
+	}
+
+	assert.Equal(t, want, got)
+	assert.LessOrEqual(t, cap(l.buf), 16*readSize)
+}
+
+func TestNumbers(t *testing.T) {
+	config := Config{
+		Rules: []Rule{
+			{Name: "LexNumber", Match: IsNumberStart, Action: LexNumber},
+		},
+		Numbers: NumberConfig{
+			Hex:         true,
+			Octal:       true,
+			Binary:      true,
+			LegacyOctal: true,
+			Float:       true,
+			Exponent:    true,
+			HexFloat:    true,
+			Separator:   '_',
+			Suffixes:    map[string]token.TokenType{"i": token.IMAG},
+		},
+	}
+
+	tests := []struct {
+		input string
+		typ   token.TokenType
+		err   string // the error and its position, if any
+	}{
+		{input: "42", typ: token.NUMBER},
+		{input: "0755", typ: token.NUMBER},
+		{input: "0o17", typ: token.NUMBER},
+		{input: "0b1010", typ: token.NUMBER},
+		{input: "0xBadFace", typ: token.NUMBER},
+		{input: "1_000_000", typ: token.NUMBER},
+		{input: "1.5", typ: token.FLOAT},
+		{input: "1.", typ: token.FLOAT},
+		{input: ".5", typ: token.FLOAT},
+		{input: "1e-9", typ: token.FLOAT},
+		{input: "0x1.8p-2", typ: token.FLOAT},
+		{input: "09.5", typ: token.FLOAT},
+		{input: "3i", typ: token.IMAG},
+		{input: "0x10i", typ: token.IMAG},
+		{input: "0x", err: "hexadecimal literal has no digits at 1:1"},
+		{input: "0b102", err: "invalid digit '2' in binary literal at 1:5"},
+		{input: "0789", err: "invalid digit '8' in octal literal at 1:3"},
+		{input: "1__0", err: "'_' must separate successive digits at 1:3"},
+		{input: "1_", err: "'_' must separate successive digits at 1:2"},
+		{input: "1e+", err: "exponent has no digits at 1:4"},
+		{input: "0x1.5", err: "hexadecimal mantissa requires a 'p' exponent at 1:1"},
+		{input: "0o1.0", err: "invalid radix point in octal literal at 1:4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := New(config).Lex(tt.input)
+			if tt.err != "" {
+				assert.EqualError(t, err, "LexNumber: "+tt.err)
+				return
+			}
+			if assert.NoError(t, err) && assert.Len(t, tokens, 1) {
+				assert.Equal(t, tt.typ, tokens[0].Type)
+				assert.Equal(t, tt.input, tokens[0].Literal)
+			}
+		})
+	}
+
+	// A radix point followed by another one or by a letter isn't part of the literal.
+	tokens, err := New(DefaultConfig).Lex("1..2 1.max")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"1", ".", ".2", "1", ".", "max"}, literals(tokens))
+}
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		input string
+		value any
+	}{
+		{input: `"a\tb"`, value: "a\tb"},
+		{input: `"\u00e9\U0001F389 \x41\101 \\ \""`, value: "é🎉 AA \\ \""},
+		{input: `"\xff"`, value: "\xff"},
+		{input: "`raw\\n`", value: `raw\n`},
+		{input: `'x'`, value: "x"},
+		{input: `'é'`, value: "é"},
+		{input: `'\''`, value: "'"},
+		{input: `'\n'`, value: "\n"},
+		{input: `'\x80'`, value: "\u0080"},
+		{input: `'\377'`, value: "\u00ff"},
+		{input: "0x10", value: big.NewInt(16)},
+		{input: "0o17", value: big.NewInt(15)},
+		{input: "1_000", value: big.NewInt(1000)},
+		{input: "1.5e3", value: big.NewFloat(1500)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := New(DefaultConfig, WithValues()).Lex(tt.input)
+			if !assert.NoError(t, err) || !assert.Len(t, tokens, 1) {
+				return
+			}
+			if want, ok := tt.value.(*big.Float); ok {
+				got, ok := tokens[0].Value.(*big.Float)
+				assert.True(t, ok && got.Cmp(want) == 0, "got %v", tokens[0].Value)
+				return
+			}
+			assert.Equal(t, tt.value, tokens[0].Value)
+		})
+	}
+
+	tokens, err := New(DefaultConfig).Lex(`"a\tb" 42`)
+	assert.NoError(t, err)
+	assert.Nil(t, tokens[0].Value)
+	assert.Nil(t, tokens[1].Value)
+
+	errs := []struct {
+		input string
+		err   string
+	}{
+		{input: `"a\qb"`, err: "LexString: unknown escape sequence at 1:3"},
+		{input: `"\x4g"`, err: "LexString: invalid character 'g' in escape sequence at 1:5"},
+		{input: `"\400"`, err: "LexString: octal escape value 256 > 255 at 1:2"},
+		{input: `"\uD800"`, err: "LexString: escape sequence is invalid Unicode code point at 1:2"},
+		{input: `''`, err: "LexChar: empty character literal at 1:1"},
+		{input: `'ab'`, err: "LexChar: character literal has more than one character at 1:1"},
+		{input: `'a`, err: "LexChar: character literal not terminated at 1:1"},
+		{input: `'\z'`, err: "LexChar: unknown escape sequence at 1:2"},
+	}
+
+	for _, tt := range errs {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := New(DefaultConfig).Lex(tt.input)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestSemicolons(t *testing.T) {
+	semicolons := Semicolons{
+		Type:     token.SEMICOLON,
+		After:    map[token.TokenType]bool{token.IDENT: true, token.RPAREN: true},
+		Continue: map[token.TokenType]bool{token.PERIOD: true},
+	}
+	input := "a\n  .b()\nc"
+
+	tokens, err := New(DefaultConfig, WithSemicolons(semicolons)).Lex(input)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"a", ".", "b", "(", ")", "", "c", ""}, literals(tokens))
+	for _, tok := range tokens {
+		if tok.Type == token.SEMICOLON {
+			assert.Equal(t, tok.Start, tok.End)
+		}
+	}
+
+	// Inserted semicolons have no text, so lossless lexing still reproduces the input.
+	tokens, err = New(DefaultConfig, WithSemicolons(semicolons), WithLossless()).Lex(input)
+	assert.NoError(t, err)
+
+	var sb strings.Builder
+	for _, tok := range tokens {
+		sb.WriteString(tok.Literal)
+	}
+	assert.Equal(t, input, sb.String())
+
+	// Semicolons are inserted before illegal tokens too.
+	tokens, err = New(DefaultConfig, WithSemicolons(semicolons), WithRecovery()).Lex("a\n$\nb")
+	assert.EqualError(t, err, "LexOperator: invalid operator '$' at 2:1")
+
+	assert.Equal(t, []string{"a", "", "$", "b", ""}, literals(tokens))
+}
+
+func TestIndentation(t *testing.T) {
+	indentation := Indentation{
+		Open:  map[token.TokenType]bool{token.LPAREN: true},
+		Close: map[token.TokenType]bool{token.RPAREN: true},
+	}
+
+	lex := func(input string, options ...Option) ([]string, error) {
+		tokens, err := New(DefaultConfig, append(options, WithIndentation(indentation))...).Lex(input)
+		got := make([]string, 0, len(tokens))
+		for _, tok := range tokens {
+			switch tok.Type {
+			case token.NEWLINE:
+				got = append(got, "NEWLINE")
+			case token.INDENT:
+				got = append(got, "INDENT")
+			case token.DEDENT:
+				got = append(got, "DEDENT")
+			default:
+				got = append(got, tok.Literal)
+			}
+		}
+		return got, err
+	}
+
+	got, err := lex("if a\n  b(c,\nd)\n\n  if e\n    f\ng\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"if", "a", "NEWLINE",
+		"INDENT", "b", "(", "c", ",", "d", ")", "NEWLINE",
+		"if", "e", "NEWLINE",
+		"INDENT", "f", "NEWLINE",
+		"DEDENT", "DEDENT", "g", "NEWLINE",
+	}, got)
+
+	got, err = lex("if a\n  b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"if", "a", "NEWLINE", "INDENT", "b", "NEWLINE", "DEDENT"}, got)
+
+	_, err = lex("if a\n    b\n  c")
+	assert.EqualError(t, err, "unindent does not match any outer indentation level at 3:3")
+
+	_, err = lex("if a\n  b\n\tc")
+	assert.EqualError(t, err, "inconsistent use of tabs and spaces in indentation at 3:2")
+
+	// Illegal tokens take part in the layout like any other, so it comes out in order.
+	got, err = lex("a\n  b\n$\n c", WithRecovery())
+	assert.EqualError(t, err, "LexOperator: invalid operator '$' at 3:1")
+	assert.Equal(t, []string{
+		"a", "NEWLINE",
+		"INDENT", "b", "NEWLINE",
+		"DEDENT", "$", "NEWLINE",
+		"INDENT", "c", "NEWLINE",
+		"DEDENT",
+	}, got)
+}
+
+func TestLongestMatch(t *testing.T) {
+	keyword := MustRegexpRule("If", `if`, token.IF)
+	keyword.Priority = 1
+
+	// pushX lexes an x and then switches modes, so it only leaves the mode switched if it wins.
+	pushX := Rule{
+		Name:  "PushX",
+		Match: func(ch rune) bool { return ch == 'x' },
+		Action: func(l *Lexer, ch rune) (token.Token, error) {
+			tok := l.StartRule(token.IDENT)
+			l.Next()
+			return l.EndRule(tok, l.PushMode("x"))
+		},
+	}
+
+	config := Config{
+		SkipWhitespace: true,
+		Rules: []Rule{
+			pushX,
+			MustRegexpRule("Int", `[0-9]+`, token.NUMBER),
+			MustRegexpRule("Float", `[0-9]+\.[0-9]+`, token.FLOAT),
+			MustRegexpRule("Ident", `[a-z]+`, token.IDENT),
+			keyword,
+		},
+		Modes: map[string]Mode{"x": {}},
+	}
+
+	l := New(config, WithLongestMatch())
+	tokens, err := l.Lex("3.14 if iffy xy 42")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMode, l.Mode())
+
+	got := make([]token.TokenType, 0, len(tokens))
+	for _, tok := range tokens {
+		got = append(got, tok.Type)
+	}
+	assert.Equal(t, []token.TokenType{token.FLOAT, token.IF, token.IDENT, token.IDENT, token.NUMBER}, got)
+	assert.Equal(t, "xy", tokens[3].Literal)
+
+	ambiguities, err := Ambiguities(config)
+	assert.NoError(t, err)
+	assert.Empty(t, ambiguities)
+
+	config.Rules[4].Priority = 0
+	ambiguities, err = Ambiguities(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []Ambiguity{{Mode: DefaultMode, Rules: []string{"Ident", "If"}, Example: "if"}}, ambiguities)
+}
+
+func TestOperators(t *testing.T) {
+	config := Config{
+		SkipWhitespace: true,
+		Rules: []Rule{
+			{Name: "LexOperator", Match: IsOperator, Action: LexOperator},
+		},
+		Operators: map[string]token.TokenType{
+			"&&":  token.LAND,
+			"&^":  token.AND_NOT,
+			"=":   token.ASSIGN,
+			"==":  token.EQL,
+			"===": 2000,
+			"...": token.ELLIPSIS,
+		},
+	}
+
+	tokens, err := New(config).Lex("= == === ==== &&&^...")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"=", "==", "===", "===", "=", "&&", "&^", "..."}, literals(tokens))
+
+	_, err = New(config).Lex("&x")
+	assert.EqualError(t, err, `LexOperator: incomplete operator "&", expected "&&" or "&^" at 1:1`)
+
+	_, err = New(config).Lex("..")
+	assert.EqualError(t, err, `LexOperator: incomplete operator "..", expected "..." at 1:1`)
+
+	_, err = New(config).Lex("@")
+	assert.EqualError(t, err, `LexOperator: invalid operator '@' at 1:1`)
+}
+
+func TestLoopDetection(t *testing.T) {
+	stall := func(l *Lexer, ch rune) (token.Token, error) {
+		tok := l.StartRule(token.IDENT)
+		return l.EndRule(tok, nil)
+	}
+	config := Config{
+		Rules: []Rule{
+			{Name: "Stall", Match: IsLetter, Action: stall},
+		},
+	}
+
+	for _, limit := range []int{0, 3} {
+		l := New(config, WithLoopLimit(limit))
+		l.Init(strings.NewReader("foo"))
+
+		for i := 0; i < limit; i++ {
+			_, err := l.NextToken()
+			assert.NoError(t, err)
+		}
+
+		_, err := l.NextToken()
+		var lerr Error
+		if assert.ErrorAs(t, err, &lerr) {
+			assert.Equal(t, "Stall", lerr.Rule)
+		}
+	}
+}
+
+func TestReuse(t *testing.T) {
+	l := New(DefaultConfig)
+
+	first, err := l.Lex("foo + bar")
+	assert.NoError(t, err)
+
+	second, err := l.Lex("foo + bar")
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(l *Lexer) {
+			defer wg.Done()
+			tokens, err := l.Lex("foo + bar")
+			assert.NoError(t, err)
+			assert.Equal(t, first, tokens)
+		}(l.Clone())
+	}
+	wg.Wait()
+}
+
+func TestLossless(t *testing.T) {
+	input := "package main\r\n\n// comment\nfunc  main() {\n\t/* block */ x := `raw\n`\n}\n"
+
+	tokens, err := New(DefaultConfig, WithLossless()).Lex(input)
+	assert.NoError(t, err)
+
+	var sb strings.Builder
+	for _, tok := range tokens {
+		sb.WriteString(tok.Literal)
+	}
+	assert.Equal(t, input, sb.String())
+}
+
+func TestRecovery(t *testing.T) {
+	config := Config{
+		SkipWhitespace: true,
+		Rules: []Rule{
+			{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+			{Name: "LexString", Match: IsDoubleQuote, Action: LexString},
+		},
+	}
+
+	tokens, err := New(config, WithRecovery()).Lex("a $ b @@ c \"d\ne")
+	assert.Equal(t, []string{"a", "$", "b", "@@", "c", "\"d", "e"}, literals(tokens))
+	assert.Equal(t, token.ILLEGAL, tokens[1].Type)
+	assert.Equal(t, token.ILLEGAL, tokens[3].Type)
+	assert.Equal(t, token.ILLEGAL, tokens[5].Type)
+
+	var errs ErrorList
+	if assert.ErrorAs(t, err, &errs) {
+		positions := make([]string, 0, len(errs))
+		for _, err := range errs {
+			positions = append(positions, err.Pos.String())
+		}
+		assert.Equal(t, []string{"1:3", "1:7", "1:12"}, positions)
+	}
+
+	// An invalid encoding fails only the token it's in, even where a rule would match U+FFFD, and
+	// is reported once.
+	config.Rules = append(config.Rules, Rule{Name: "LexOperator", Match: IsOperator, Action: LexOperator})
+	config.Operators = map[string]token.TokenType{"+": token.ADD}
+
+	tokens, err = New(config, WithRecovery()).Lex("é\xffx + \xff\xfe")
+	assert.Equal(t, []string{"é", "\xff", "x", "+", "\xff\xfe"}, literals(tokens))
+	assert.Equal(t, token.IDENT, tokens[0].Type)
+	assert.Equal(t, token.ILLEGAL, tokens[1].Type)
+	assert.Equal(t, token.ILLEGAL, tokens[4].Type)
+	assert.EqualError(t, err, "2 errors:\ninvalid UTF-8 encoding at 1:2\ninvalid UTF-8 encoding at 1:7")
+}
+
+func TestModes(t *testing.T) {
+	const (
+		QUOTE token.TokenType = token.LiteralStart + iota
+		INTERP_START
+		INTERP_END
+	)
+
+	// switchMode returns an action that lexes literal as a token of type typ and then pushes the
+	// named mode, or pops the current mode if the name is empty.
+	switchMode := func(literal string, typ token.TokenType, name string) Action {
+		return func(l *Lexer, ch rune) (token.Token, error) {
+			tok := l.StartRule(typ)
+			if !l.hasPrefix(literal) {
+				return l.EndRule(tok, Error{Lexer: l, Msg: "no match", GotoNextRule: true})
+			}
+			l.skip(literal)
+			if name == "" {
+				return l.EndRule(tok, l.PopMode())
+			}
+			return l.EndRule(tok, l.PushMode(name))
+		}
+	}
+
+	lexText := func(l *Lexer, ch rune) (token.Token, error) {
+		tok := l.StartRule(token.STRING)
+		for !IsEOF(ch) && !IsDoubleQuote(ch) && !l.hasPrefix("${") {
+			ch = l.Next()
+		}
+		return l.EndRule(tok, nil)
+	}
+
+	config := Config{
+		SkipWhitespace: true,
+		Rules: []Rule{
+			{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+			{Name: "StringStart", Match: IsDoubleQuote, Action: switchMode(`"`, QUOTE, "string")},
+		},
+		Modes: map[string]Mode{
+			"string": {
+				Rules: []Rule{
+					{Name: "StringEnd", Match: IsDoubleQuote, Action: switchMode(`"`, QUOTE, "")},
+					{Name: "InterpStart", Match: func(ch rune) bool { return ch == '$' }, Action: switchMode("${", INTERP_START, "interp")},
+					{Name: "Text", Match: func(ch rune) bool { return !IsEOF(ch) }, Action: lexText},
+				},
+			},
+			"interp": {
+				SkipWhitespace: true,
+				Rules: []Rule{
+					{Name: "LexIdentifier", Match: IsLetter, Action: LexIdentifier},
+					{Name: "InterpEnd", Match: func(ch rune) bool { return ch == '}' }, Action: switchMode("}", INTERP_END, "")},
+					{Name: "LexOperator", Match: IsOperator, Action: LexOperator},
+				},
+				Operators: map[string]token.TokenType{
+					"+": token.ADD,
+				},
+			},
+		},
+	}
+
+	l := New(config)
+	tokens, err := l.Lex(`x "a ${b + c} $d"`)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMode, l.Mode())
+
+	assert.Equal(t, []string{"x", `"`, "a ", "${", "b", "+", "c", "}", " $d", `"`}, literals(tokens))
+	assert.Equal(t, token.ADD, tokens[5].Type)
+
+	assert.Error(t, New(config).PopMode())
+	assert.Error(t, New(config).PushMode("nope"))
+}
+
+func TestCompile(t *testing.T) {
+	config := Config{
+		SkipWhitespace: true,
+		Rules: []Rule{
+			MustRegexpRule("Ident", `\pL\w*`, token.IDENT),
+			MustRegexpRule("Int", `[0-9]+`, token.NUMBER),
+		},
+		Keywords: map[string]token.TokenType{
+			"if": token.IF,
+		},
+		Operators: map[string]token.TokenType{
+			"=":  token.ASSIGN,
+			"==": token.EQL,
+		},
+	}
+
+	l, err := Compile(config)
+	assert.NoError(t, err)
+
+	tokens, err := l.Lex("if iffy == 42")
+	assert.NoError(t, err)
+
+	got := make([]token.TokenType, 0, len(tokens))
+	for _, tok := range tokens {
+		got = append(got, tok.Type)
+	}
+	assert.Equal(t, []token.TokenType{token.IF, token.IDENT, token.EQL, token.NUMBER}, got)
+
+	// Comments are lexed before the DFA is tried, and errors resync as usual.
+	config.LineComments = []string{"//"}
+	l, err = Compile(config, WithRecovery())
+	assert.NoError(t, err)
+
+	tokens, err = l.Lex("x = 1 // if\n$ é")
+	assert.EqualError(t, err, "no rule to handle character '$' at 2:1")
+	assert.Equal(t, []string{"x", "=", "1", "// if", "$", "é"}, literals(tokens))
+	assert.Equal(t, token.COMMENT, tokens[3].Type)
+	assert.Equal(t, token.Position{Pos: 14, Line: 2, Column: 3}, tokens[5].Start)
+	assert.Equal(t, token.Position{Pos: 16, Line: 2, Column: 4}, tokens[5].End)
+
+	config.Rules = append(config.Rules, Rule{Name: "LexString", Match: IsDoubleQuote, Action: LexString})
+	_, err = Compile(config)
+	assert.Error(t, err)
+}
+
+func TestRelex(t *testing.T) {
+	input := "foo := bar + 42\nbaz(\"qux\", 'x')\n// done\n"
+
+	edits := []Edit{
+		{Start: 3, End: 3, Text: "d"},                   // grow a token
+		{Start: 7, End: 10, Text: "b"},                  // shrink one
+		{Start: 13, End: 15, Text: "4.2\n\n"},           // add lines
+		{Start: 15, End: 17, Text: ""},                  // join lines
+		{Start: 16, End: 21, Text: "\"quux"},            // open a string that swallows the rest
+		{Start: 0, End: 0, Text: "x "},                  // insert at the start
+		{Start: len(input), End: len(input), Text: "y"}, // append
+	}
+
+	l := New(DefaultConfig)
+	for _, edit := range edits {
+		tokens, err := l.Lex(input)
+		assert.NoError(t, err)
+
+		edited := input[:edit.Start] + edit.Text + input[edit.End:]
+		want, wantErr := l.Clone().Lex(edited)
+
+		got, change, err := l.Relex(input, edited, tokens, edit)
+		if wantErr != nil {
+			assert.EqualError(t, err, wantErr.Error())
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "edit %+v", edit)
+		assert.Equal(t, len(tokens)-change.OldEnd, len(got)-change.End, "edit %+v", edit)
+		assert.Equal(t, tokens[:change.Start], got[:change.Start], "edit %+v", edit)
+	}
+
+	tokens, err := l.Lex(input)
+	assert.NoError(t, err)
+	_, change, err := l.Relex(input, input[:3]+"d"+input[3:], tokens, edits[0])
+	assert.NoError(t, err)
+	assert.Equal(t, Change{Start: 0, End: 1, OldEnd: 1}, change)
+
+	// Edits have to fit the old input, and turn it into one as long as the new.
+	for _, edit := range []Edit{
+		{Start: 3, End: 100},
+		{Start: -1, End: 0},
+		{Start: 3, End: 2},
+		{Start: 3, End: 3, Text: "dd"},
+	} {
+		_, _, err = l.Relex(input, input[:3]+"d"+input[3:], tokens, edit)
+		assert.EqualError(t, err, "lexer: edit is out of range", "edit %+v", edit)
+	}
+
+	tokens, _, err = l.Relex("", "a + b", nil, Edit{Start: 0, End: 0, Text: "a + b"})
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 3)
+}
+
+func TestLexFile(t *testing.T) {
+	fset := token.NewFileSet()
+	l := New(DefaultConfig)
+
+	input := "a\r\nb 'c' \"d\\\"\"\ne\n"
+	file := fset.AddFile("a.txt", -1, len(input))
+	tokens, err := l.LexFile(file, input)
+	assert.NoError(t, err)
+
+	// The lexer's positions match those looked up in the file's tables, which it built.
+	for _, tok := range tokens {
+		assert.Equal(t, fset.Position(file.Pos(tok.Start.Pos)), tok.Start, tok.Literal)
+		assert.Equal(t, fset.Position(file.Pos(tok.End.Pos)), tok.End, tok.Literal)
+	}
+	assert.Equal(t, 4, file.LineCount())
+	assert.Equal(t, "a.txt:3:1", fset.Format(file.Pos(tokens[len(tokens)-1].Start.Pos)))
+
+	// They match in any encoding.
+	input = "\"🎉\" é\n\"é\" ü"
+	for _, enc := range []token.Encoding{token.Runes, token.UTF8, token.UTF16} {
+		file := fset.AddFile("b.txt", -1, len(input))
+		tokens, err := New(DefaultConfig, WithEncoding(enc)).LexFile(file, input)
+		assert.NoError(t, err)
+
+		for _, tok := range tokens {
+			assert.Equal(t, file.Position(file.Pos(tok.Start.Pos)), tok.Start, "%s %s", enc, tok.Literal)
+			assert.Equal(t, file.Position(file.Pos(tok.End.Pos)), tok.End, "%s %s", enc, tok.Literal)
+		}
+	}
+
+	input = "x\n  $"
+	_, err = l.LexFile(fset.AddFile("c.txt", -1, len(input)), input)
+	assert.EqualError(t, err, `LexOperator: invalid operator '$' at c.txt:2:3`)
+
+	_, err = l.Lex(input)
+	assert.EqualError(t, err, `LexOperator: invalid operator '$' at 2:3`)
+}
+
+func TestEncoding(t *testing.T) {
+	input := "\"🎉\" x\n\"é\" y"
+
+	for _, enc := range []token.Encoding{token.Runes, token.UTF8, token.UTF16} {
+		tokens, err := New(DefaultConfig, WithEncoding(enc)).Lex(input)
+		assert.NoError(t, err)
+
+		for _, tok := range tokens {
+			assert.Equal(t, enc.Convert(input, tok.Start), tok.Start, "%s %s", enc, tok.Literal)
+			assert.Equal(t, enc.Convert(input, tok.End), tok.End, "%s %s", enc, tok.Literal)
+		}
+	}
+
+	tokens, err := New(DefaultConfig, WithEncoding(token.UTF16)).Lex(input)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, tokens[1].Start.Column)
+}
+
+func TestLimits(t *testing.T) {
+	_, err := New(DefaultConfig, WithMaxInputSize(8)).Lex("foo + bar")
+	assert.ErrorIs(t, err, InputSizeError{Max: 8})
+
+	l := New(DefaultConfig, WithMaxInputSize(readSize))
+	l.Init(strings.NewReader(strings.Repeat("foo ", readSize)))
+	for err = nil; err == nil; {
+		_, err = l.NextToken()
+	}
+	assert.EqualError(t, err, fmt.Sprintf("input exceeds the limit of %d bytes", readSize))
+
+	tokens, err := New(DefaultConfig, WithMaxTokens(3)).Lex("foo + bar")
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 3)
+
+	_, err = New(DefaultConfig, WithMaxTokens(3)).Lex("foo + bar + baz")
+	var terr TokenLimitError
+	if assert.ErrorAs(t, err, &terr) {
+		assert.Equal(t, "input exceeds the limit of 3 tokens at 1:11", terr.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = New(DefaultConfig).LexContext(ctx, "foo + bar")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Going over a limit doesn't cost memory in proportion to the input.
+	large := strings.Repeat("foo + bar\n", 1<<20)
+	allocated := func(lex func() error) uint64 {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		assert.Error(t, lex())
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+
+	assert.Less(t, allocated(func() error {
+		_, err := New(DefaultConfig, WithMaxInputSize(1024)).Lex(large)
+		return err
+	}), uint64(64*1024))
+	assert.Less(t, allocated(func() error {
+		_, err := New(DefaultConfig, WithMaxTokens(10)).Lex(large)
+		return err
+	}), uint64(64*1024))
+	assert.Less(t, allocated(func() error {
+		_, err := New(DefaultConfig, WithMaxTokens(10)).LexSpans(large)
+		return err
+	}), uint64(64*1024))
+}
+
+func TestLexSpans(t *testing.T) {
+	input := "foo := \"bär\"\n\tbaz(42) // done\n"
+
+	want, err := New(DefaultConfig).LexFile(token.NewFileSet().AddFile("a.txt", -1, len(input)), input)
+	assert.NoError(t, err)
+
+	spans, err := New(DefaultConfig).LexSpans(input)
+	assert.NoError(t, err)
+
+	file := token.NewFileSet().AddFile("a.txt", -1, len(input))
+	file.SetLinesForContent(input, token.Runes)
+
+	got := make([]token.Token, 0, len(spans))
+	for _, span := range spans {
+		got = append(got, span.Token(file, input))
+	}
+	assert.Equal(t, want, got)
+
+	_, err = New(DefaultConfig).LexSpans("foo $")
+	assert.Error(t, err)
+}
+
+func TestAllocs(t *testing.T) {
+	l := New(DefaultConfig)
+	input := "foo := bar(42, \"baz\") // done\nqux[0] += 1.5\n"
+
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Reset(input)
+		for {
+			if _, err := l.NextToken(); err != nil {
+				break
+			}
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestDiagnostic(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			"x := \"abc\ny",
+			"error: LexString: literal newlines aren't valid in a string\n" +
+				" --> 1:6\n" +
+				"  |\n" +
+				"1 | x := \"abc\n" +
+				"  |      ^~~~\n",
+		},
+		{
+			"x := `abc",
+			"error: LexRawString: raw string literal not terminated\n" +
+				" --> 1:6\n" +
+				"  |\n" +
+				"1 | x := `abc\n" +
+				"  |      ^~~~\n",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := New(DefaultConfig).Lex(tt.input)
+
+		var lerr Error
+		if assert.ErrorAs(t, err, &lerr, tt.input) {
+			assert.Equal(t, tt.want, diagnostic.Render(tt.input, lerr.Diagnostic()), tt.input)
+		}
+	}
+}
+
+// literals returns the literals of tokens.
+func literals(tokens []token.Token) []string {
+	got := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		got = append(got, tok.Literal)
+	}
+	return got
+}
@@ -0,0 +1,161 @@
+package lexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rdeusser/parsekit/internal/dfa"
+	"github.com/rdeusser/parsekit/token"
+)
+
+// state is the part of a lexer's state that actions can change.
+type state struct {
+	curPos      token.Position
+	prevPos     token.Position
+	stack       []*mode
+	encodingErr error
+}
+
+// longest holds the states that lexLongest saves, kept between tokens to reuse their stacks.
+type longest struct {
+	start state // before trying any rule
+	best  state // after the longest match so far
+	err   state // after the first error
+}
+
+func (l *Lexer) save(s *state) {
+	s.curPos, s.prevPos, s.encodingErr = l.curPos, l.prevPos, l.encodingErr
+	s.stack = append(s.stack[:0], l.stack...)
+}
+
+func (l *Lexer) restore(s *state) {
+	l.curPos, l.prevPos, l.encodingErr = s.curPos, s.prevPos, s.encodingErr
+	l.stack = append(l.stack[:0], s.stack...)
+	l.mode = l.stack[len(l.stack)-1]
+}
+
+// lexLongest tries every rule that matches ch and keeps the longest token, breaking ties by
+// priority and then by order. If no rule lexes a token, the first error is returned.
+func (l *Lexer) lexLongest(ch rune) (token.Token, error) {
+	l.save(&l.longest.start)
+
+	var (
+		best     token.Token
+		bestRule Rule
+		found    bool
+		firstErr error
+	)
+
+	for _, rule := range l.longest.start.stack[len(l.longest.start.stack)-1].rules {
+		if l.debug {
+			l.logger.Debug("Attempting to match %q with char %q", rule.Name, ch)
+		}
+
+		if !rule.Match(ch) {
+			continue
+		}
+
+		l.restore(&l.longest.start)
+		tok, ok, err := l.runRule(rule, ch)
+		switch {
+		case !ok:
+			continue
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+				l.save(&l.longest.err)
+			}
+			continue
+		}
+
+		if !found || tok.End.Pos > best.End.Pos || tok.End.Pos == best.End.Pos && rule.Priority > bestRule.Priority {
+			best, bestRule, found = tok, rule, true
+			l.save(&l.longest.best)
+		}
+	}
+
+	switch {
+	case found:
+		l.restore(&l.longest.best)
+		l.loopDetector.Mark(l.longest.start.curPos.Pos)
+		return l.detectLoop(bestRule, best)
+	case firstErr != nil:
+		l.restore(&l.longest.err)
+		return token.NoToken, firstErr
+	}
+
+	l.restore(&l.longest.start)
+	return token.NoToken, Error{Lexer: l, Msg: fmt.Sprintf("no rule to handle character %q", ch), Pos: l.curPos}
+}
+
+// Ambiguity is a set of rules that match the same token with the same priority, so that only the
+// order they're listed in decides which one lexes it.
+type Ambiguity struct {
+	Mode    string
+	Rules   []string // names of the rules
+	Example string   // the shortest token they all match
+}
+
+func (a Ambiguity) String() string {
+	return fmt.Sprintf("rules %s in mode %q all match %q", strings.Join(a.Rules, ", "), a.Mode, a.Example)
+}
+
+// Ambiguities finds the rules in every mode of config that tie when lexing with WithLongestMatch
+// or a compiled lexer. Only rules with a Pattern, like those made by RegexpRule, can be checked.
+func Ambiguities(config Config) ([]Ambiguity, error) {
+	modes := modes(config)
+
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ambiguities := make([]Ambiguity, 0)
+	for _, name := range names {
+		rules := make([]Rule, 0, len(modes[name].Rules))
+		patterns := make([]string, 0, len(modes[name].Rules))
+		for _, rule := range modes[name].Rules {
+			if rule.Pattern != "" {
+				rules = append(rules, rule)
+				patterns = append(patterns, rule.Pattern)
+			}
+		}
+		if len(patterns) < 2 {
+			continue
+		}
+
+		d, err := dfa.Compile(patterns)
+		if err != nil {
+			return nil, fmt.Errorf("lexer: mode %q: %w", name, err)
+		}
+
+		reported := make(map[string]bool)
+		for _, a := range d.Ambiguities() {
+			top := rules[a.Patterns[0]].Priority
+			for _, p := range a.Patterns {
+				if rules[p].Priority > top {
+					top = rules[p].Priority
+				}
+			}
+
+			tied := make([]string, 0, len(a.Patterns))
+			for _, p := range a.Patterns {
+				if rules[p].Priority == top {
+					tied = append(tied, rules[p].Name)
+				}
+			}
+
+			key := strings.Join(tied, "\x00")
+			if len(tied) < 2 || reported[key] {
+				continue
+			}
+			reported[key] = true
+
+			ambiguities = append(ambiguities, Ambiguity{Mode: name, Rules: tied, Example: a.Example})
+		}
+	}
+
+	return ambiguities, nil
+}
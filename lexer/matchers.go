@@ -16,6 +16,12 @@ func IsNumber(ch rune) bool {
 	return unicode.IsNumber(ch)
 }
 
+// IsNumberStart reports whether ch can start a numeric literal: an ASCII digit or, for floats
+// like .5, a radix point.
+func IsNumberStart(ch rune) bool {
+	return '0' <= ch && ch <= '9' || ch == '.'
+}
+
 func IsSingleQuote(ch rune) bool {
 	return ch == '\''
 }
@@ -0,0 +1,123 @@
+package lexer
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/rdeusser/parsekit/internal/dfa"
+	"github.com/rdeusser/parsekit/token"
+)
+
+// DefaultMode is the name of the mode made up of the top-level fields of a Config.
+const DefaultMode = "default"
+
+// Mode is a named set of rules, operators, and keywords. Modes let the same characters lex
+// differently depending on context, such as the text of a string versus an expression
+// interpolated into it. Actions switch modes with PushMode and PopMode.
+type Mode struct {
+	SkipWhitespace bool
+	SkipComments   bool
+	LineComments   []string
+	BlockComments  []BlockComment
+	Rules          []Rule
+	Operators      map[string]token.TokenType
+	Keywords       map[string]token.TokenType
+	Numbers        NumberConfig
+}
+
+// mode is a Mode along with the tables built from it.
+type mode struct {
+	Mode
+	name      string
+	rules     []Rule
+	operators *trie
+	dfa       *dfa.DFA          // set by Compile
+	types     []token.TokenType // the type of each of the DFA's patterns
+	comment   *Rule             // the rule for comments in a compiled mode, if it has any
+}
+
+// modes builds every mode in config, including the default mode.
+func modes(config Config) map[string]*mode {
+	modes := make(map[string]*mode, len(config.Modes)+1)
+	for name, m := range config.Modes {
+		modes[name] = newMode(name, m)
+	}
+
+	modes[DefaultMode] = newMode(DefaultMode, Mode{
+		SkipWhitespace: config.SkipWhitespace,
+		SkipComments:   config.SkipComments,
+		LineComments:   config.LineComments,
+		BlockComments:  config.BlockComments,
+		Rules:          config.Rules,
+		Operators:      config.Operators,
+		Keywords:       config.Keywords,
+		Numbers:        config.Numbers,
+	})
+
+	return modes
+}
+
+func newMode(name string, m Mode) *mode {
+	return &mode{
+		Mode:      m,
+		name:      name,
+		rules:     rules(m),
+		operators: newTrie(m.Operators),
+	}
+}
+
+// rules returns the rules to try in order. Comments are tried before any user-defined rule so
+// that they take precedence over operators sharing a prefix with them.
+func rules(m Mode) []Rule {
+	if len(m.LineComments) == 0 && len(m.BlockComments) == 0 {
+		return m.Rules
+	}
+
+	rules := make([]Rule, 0, len(m.Rules)+1)
+	rules = append(rules, Rule{Name: "LexComment", Match: commentMatcher(m), Action: LexComment})
+	rules = append(rules, m.Rules...)
+
+	return rules
+}
+
+// commentMatcher matches the first character of any comment delimiter in m.
+func commentMatcher(m Mode) Matcher {
+	first := make(map[rune]bool)
+	for _, prefix := range m.LineComments {
+		ch, _ := utf8.DecodeRuneInString(prefix)
+		first[ch] = true
+	}
+	for _, block := range m.BlockComments {
+		ch, _ := utf8.DecodeRuneInString(block.Start)
+		first[ch] = true
+	}
+	return func(ch rune) bool {
+		return first[ch]
+	}
+}
+
+// PushMode switches to the named mode until the matching PopMode.
+func (l *Lexer) PushMode(name string) error {
+	m, ok := l.modes[name]
+	if !ok {
+		return fmt.Errorf("unknown lexer mode %q", name)
+	}
+	l.stack = append(l.stack, m)
+	l.mode = m
+	return nil
+}
+
+// PopMode switches back to the mode that was current before the last PushMode.
+func (l *Lexer) PopMode() error {
+	if len(l.stack) <= 1 {
+		return fmt.Errorf("can't pop lexer mode %q: it's the only mode on the stack", l.mode.name)
+	}
+	l.stack = l.stack[:len(l.stack)-1]
+	l.mode = l.stack[len(l.stack)-1]
+	return nil
+}
+
+// Mode returns the name of the current mode.
+func (l *Lexer) Mode() string {
+	return l.mode.name
+}
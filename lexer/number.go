@@ -0,0 +1,290 @@
+package lexer
+
+import (
+	"fmt"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+// NumberConfig configures the numeric literals that LexNumber accepts. The zero value accepts
+// decimal integers only.
+type NumberConfig struct {
+	Hex         bool // 0x1F
+	Octal       bool // 0o17
+	Binary      bool // 0b1010
+	LegacyOctal bool // 017, where a leading zero means octal
+	Float       bool // 1.5, 1. and .5
+	Exponent    bool // 1e-9
+	HexFloat    bool // 0x1.8p-2; needs Hex, and Float or Exponent
+
+	// Separator is an ASCII character that can separate successive digits, such as '_' in
+	// 1_000_000. Zero means digits can't be separated.
+	Separator rune
+
+	// Suffixes are the suffixes a literal can end with, such as "i" for Go's imaginary numbers or
+	// "u" and "ul" for C's unsigned integers, mapped to the type of the literal they make. A type of
+	// token.ILLEGAL keeps the literal's type, so "f" can mark a float without changing its type.
+	Suffixes map[string]token.TokenType
+}
+
+// LexNumber lexes a numeric literal as configured by Numbers in the current mode. Literals are
+// token.NUMBER unless they have a radix point or an exponent, which makes them token.FLOAT, or a
+// suffix with a type of its own. Malformed literals are lexed to their end and reported at the
+// offending character.
+func LexNumber(l *Lexer, ch rune) (token.Token, error) {
+	tok := l.StartRule(token.NUMBER)
+	config := l.mode.Numbers
+	n := &number{l: l, config: config}
+
+	prefix := rune(0)
+	digits := false
+
+	if ch != '.' {
+		base := 10
+		if ch == '0' {
+			switch lower(l.peek()) {
+			case 'x':
+				if config.Hex {
+					base, prefix = 16, 'x'
+				}
+			case 'o':
+				if config.Octal {
+					base, prefix = 8, 'o'
+				}
+			case 'b':
+				if config.Binary {
+					base, prefix = 2, 'b'
+				}
+			default:
+				if config.LegacyOctal {
+					base, prefix = 8, '0'
+					digits = true // the leading zero counts as a digit
+				}
+			}
+
+			if base != 10 && prefix != '0' {
+				_ = l.Next()
+				ch = l.Next()
+			}
+		}
+
+		var ok bool
+		ch, ok = n.digits(ch, base)
+		digits = digits || ok
+
+		if ch == '.' && config.Float && n.radixPoint(prefix) {
+			tok.Type = token.FLOAT
+			if prefix == 'o' || prefix == 'b' || prefix == 'x' && !config.HexFloat {
+				n.errorAt(l.curPos, "invalid radix point in %s", litName(prefix))
+			}
+			ch, ok = n.digits(l.Next(), base)
+			digits = digits || ok
+		}
+
+		if !digits {
+			n.error(tok.Start, "%s has no digits", litName(prefix))
+		}
+	} else {
+		if !config.Float || !isDigit(l.peek(), 10) {
+			return l.EndRule(tok, Error{Lexer: l, Msg: "not a number", GotoNextRule: true})
+		}
+
+		tok.Type = token.FLOAT
+		ch, _ = n.digits(l.Next(), 10)
+	}
+
+	if e := lower(ch); e == 'e' && config.Exponent || e == 'p' && config.HexFloat {
+		switch {
+		case e == 'e' && prefix != 0 && prefix != '0':
+			n.errorAt(l.curPos, "'%c' exponent requires decimal mantissa", ch)
+		case e == 'p' && prefix != 'x':
+			n.errorAt(l.curPos, "'%c' exponent requires hexadecimal mantissa", ch)
+		}
+
+		tok.Type = token.FLOAT
+		ch = l.Next()
+		if ch == '+' || ch == '-' {
+			ch = l.Next()
+		}
+
+		var ok bool
+		if ch, ok = n.digits(ch, 10); !ok {
+			n.errorAt(l.curPos, "exponent has no digits")
+		}
+	} else if prefix == 'x' && tok.Type == token.FLOAT {
+		n.error(tok.Start, "hexadecimal mantissa requires a 'p' exponent")
+	}
+
+	if config.Separator != 0 {
+		if i := invalidSeparator(l.slice(tok.Start.Pos, l.curPos.Pos), prefix, config.Separator); i >= 0 {
+			n.errorAt(offset(tok.Start, i), "'%c' must separate successive digits", config.Separator)
+		}
+	}
+
+	float, end := tok.Type == token.FLOAT, l.curPos
+	if suffix := n.suffix(); suffix != "" {
+		if typ := config.Suffixes[suffix]; typ != token.ILLEGAL {
+			tok.Type = typ
+		}
+		l.skip(suffix)
+	}
+
+	// Legacy octal literals with an 8 or 9 in them are only valid as floats and as literals with a
+	// suffix that gives them a type of their own, which are always decimal.
+	if n.invalid.IsValid() && (prefix != '0' || tok.Type == token.NUMBER) {
+		n.errorAt(n.invalid, "invalid digit %q in %s", n.invalidDigit, litName(prefix))
+	}
+
+	if n.err != nil {
+		return l.EndRule(tok, *n.err)
+	}
+
+	if l.values {
+		// Legacy octal literals with a suffix that gives them a type of their own are decimal.
+		decimal := tok.Type != token.NUMBER && tok.Type != token.FLOAT
+		tok.Value = numberValue(l.slice(tok.Start.Pos, end.Pos), prefix, float, decimal, config.Separator)
+	}
+
+	return l.EndRule(tok, nil)
+}
+
+// number holds the state of LexNumber while it lexes a literal.
+type number struct {
+	l            *Lexer
+	config       NumberConfig
+	err          *Error         // the first error in the literal
+	invalid      token.Position // position of the first digit that's invalid in the literal's base
+	invalidDigit rune
+}
+
+// digits lexes a run of digits and separators, noting the first digit that's invalid in base, and
+// reports whether there were any digits.
+func (n *number) digits(ch rune, base int) (rune, bool) {
+	ok := false
+	for {
+		switch {
+		case n.config.Separator != 0 && ch == n.config.Separator:
+		case isDigit(ch, base):
+			ok = true
+		case base < 10 && isDigit(ch, 10):
+			ok = true
+			if !n.invalid.IsValid() {
+				n.invalid, n.invalidDigit = n.l.curPos, ch
+			}
+		default:
+			return ch, ok
+		}
+		ch = n.l.Next()
+	}
+}
+
+// radixPoint reports whether the '.' at the current position belongs to the literal. It doesn't
+// if it's followed by another '.' or by a letter that can't continue the literal, so that ranges
+// like 1..2 and method calls like 1.max(2) aren't taken for floats.
+func (n *number) radixPoint(prefix rune) bool {
+	ch := n.l.peek()
+	switch {
+	case ch == '.':
+		return false
+	case isDigit(ch, 10) || prefix == 'x' && isDigit(ch, 16):
+		return true
+	case lower(ch) == 'e':
+		return n.config.Exponent
+	case lower(ch) == 'p':
+		return n.config.HexFloat && prefix == 'x'
+	}
+	return !IsLetter(ch)
+}
+
+// suffix returns the longest configured suffix at the current position, if any.
+func (n *number) suffix() string {
+	longest := ""
+	for suffix := range n.config.Suffixes {
+		if len(suffix) > len(longest) && n.l.hasPrefix(suffix) {
+			longest = suffix
+		}
+	}
+	return longest
+}
+
+// error records an error spanning from pos to the current position, unless there already is one.
+func (n *number) error(pos token.Position, format string, args ...any) {
+	if n.err == nil {
+		n.err = &Error{Lexer: n.l, Msg: fmt.Sprintf(format, args...), Pos: pos, End: n.l.curPos}
+	}
+}
+
+// errorAt records an error at the single character at pos, unless there already is one.
+func (n *number) errorAt(pos token.Position, format string, args ...any) {
+	if n.err == nil {
+		n.err = &Error{Lexer: n.l, Msg: fmt.Sprintf(format, args...), Pos: pos, End: offset(pos, 1)}
+	}
+}
+
+// invalidSeparator returns the index of the first separator in lit that doesn't separate two
+// digits, or -1 if there isn't one. A base prefix counts as a digit.
+func invalidSeparator(lit string, prefix, sep rune) int {
+	i := 0
+	d := '.' // the previous character: '_' for a separator, '0' for a digit, and '.' for anything else
+	if prefix != 0 && prefix != '0' {
+		i, d = 2, '0'
+	}
+
+	for ; i < len(lit); i++ {
+		p := d
+		switch c := rune(lit[i]); {
+		case c == sep:
+			if p != '0' {
+				return i
+			}
+			d = '_'
+		case isDigit(c, 10) || prefix == 'x' && isDigit(c, 16):
+			d = '0'
+		default:
+			if p == '_' {
+				return i - 1
+			}
+			d = '.'
+		}
+	}
+	if d == '_' {
+		return len(lit) - 1
+	}
+
+	return -1
+}
+
+// offset returns the position n bytes after pos on the same line.
+func offset(pos token.Position, n int) token.Position {
+	pos.Pos += n
+	pos.Column += n
+	return pos
+}
+
+func litName(prefix rune) string {
+	switch prefix {
+	case 'x':
+		return "hexadecimal literal"
+	case 'o', '0':
+		return "octal literal"
+	case 'b':
+		return "binary literal"
+	}
+	return "decimal literal"
+}
+
+// isDigit reports whether ch is an ASCII digit in base, which is at most 16.
+func isDigit(ch rune, base int) bool {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch-'0') < base
+	case 'a' <= lower(ch) && lower(ch) <= 'f':
+		return int(lower(ch)-'a')+10 < base
+	}
+	return false
+}
+
+// lower returns the lower-case version of an ASCII letter.
+func lower(ch rune) rune {
+	return ('a' - 'A') | ch
+}
@@ -0,0 +1,137 @@
+package lexer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+// RegexpRule returns a rule that lexes the longest match of pattern at the current position as a
+// token of type typ. As with any other rule, a match that's also an operator or keyword is given
+// the operator's or keyword's type instead. Rules built this way can be mixed freely with other
+// rules.
+func RegexpRule(name, pattern string, typ token.TokenType) (Rule, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)`)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %w", name, err)
+	}
+	re.Longest()
+
+	match, err := firstRune(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %w", name, err)
+	}
+
+	return Rule{
+		Name:    name,
+		Match:   match,
+		Action:  lexRegexp(re, typ),
+		Pattern: pattern,
+		Type:    typ,
+	}, nil
+}
+
+// MustRegexpRule is like RegexpRule but panics if the pattern can't be compiled.
+func MustRegexpRule(name, pattern string, typ token.TokenType) Rule {
+	rule, err := RegexpRule(name, pattern, typ)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+func lexRegexp(re *regexp.Regexp, typ token.TokenType) Action {
+	return func(l *Lexer, ch rune) (token.Token, error) {
+		tok := l.StartRule(typ)
+
+		var loc []int
+		if !l.stream {
+			loc = re.FindStringIndex(l.input[l.curPos.Pos:])
+		} else {
+			loc = re.FindReaderIndex(&runeReader{l: l, pos: l.curPos.Pos})
+		}
+
+		if loc == nil || loc[1] == 0 {
+			return l.EndRule(tok, Error{Lexer: l, Msg: "no match", GotoNextRule: true})
+		}
+
+		for end := tok.Start.Pos + loc[1]; l.curPos.Pos < end; {
+			_ = l.Next()
+		}
+
+		return l.EndRule(tok, nil)
+	}
+}
+
+// firstRune returns a matcher for the characters a match of pattern can start with.
+func firstRune(pattern string) (Matcher, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		insts   []*syntax.Inst
+		anyRune bool
+		visited = make(map[uint32]bool)
+		walk    func(pc uint32)
+	)
+	walk = func(pc uint32) {
+		if visited[pc] {
+			return
+		}
+		visited[pc] = true
+
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			walk(inst.Out)
+			walk(inst.Arg)
+		case syntax.InstCapture, syntax.InstNop, syntax.InstEmptyWidth:
+			walk(inst.Out)
+		case syntax.InstMatch:
+			anyRune = true
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			insts = append(insts, inst)
+		}
+	}
+	walk(uint32(prog.Start))
+
+	return func(ch rune) bool {
+		if IsEOF(ch) {
+			return false
+		}
+		if anyRune {
+			return true
+		}
+		for _, inst := range insts {
+			if inst.MatchRune(ch) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// runeReader reads runes from the lexer's input without consuming them.
+type runeReader struct {
+	l   *Lexer
+	pos int
+}
+
+func (r *runeReader) ReadRune() (rune, int, error) {
+	ch, size := r.l.decode(r.pos)
+	if size == 0 {
+		return 0, 0, io.EOF
+	}
+	r.pos += size
+	return ch, size, nil
+}
@@ -0,0 +1,131 @@
+package lexer
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+// Edit is a change to the input: the bytes from Start up to End are replaced by Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Change describes the tokens that Relex replaced: the old tokens[Start:OldEnd] became
+// tokens[Start:End] of the new ones.
+type Change struct {
+	Start  int
+	End    int
+	OldEnd int
+}
+
+// Relex updates tokens, the result of lexing old, for an edit that turned old into input. Lexing
+// restarts at the last token before the edit and stops as soon as it lexes a token after the edit
+// that matches an old one, at which point the rest of the old tokens are reused with their
+// positions shifted. It returns the new tokens along with the range that changed.
+//
+// Lexing can only restart in the middle of the input if the lexer carries no state from one token
+// to the next beyond the last one, so lexers with modes or indentation can't relex.
+func (l *Lexer) Relex(old, input string, tokens []token.Token, edit Edit) ([]token.Token, Change, error) {
+	if len(l.config.Modes) > 0 || l.indentation != nil {
+		return nil, Change{}, errors.New("lexer: lexers with modes or indentation can't relex")
+	}
+
+	delta := len(edit.Text) - (edit.End - edit.Start)
+	if edit.Start < 0 || edit.End < edit.Start || edit.End > len(old) || len(old)+delta != len(input) {
+		return nil, Change{}, errors.New("lexer: edit is out of range")
+	}
+
+	editEnd := edit.Start + len(edit.Text) // the end of the edit in the new input
+
+	// Restart after the last token before the edit that isn't whitespace, a comment, or inserted,
+	// so that the whitespace after it is lexed again and semicolons are inserted as they would be.
+	// A token ending right where the edit starts could grow, like an identifier being typed.
+	k := sort.Search(len(tokens), func(i int) bool { return tokens[i].End.Pos >= edit.Start }) - 1
+	for k >= 0 && !significant(tokens[k]) {
+		k--
+	}
+	start := k + 1
+
+	l.file = nil
+	l.reset(input, nil)
+	if k >= 0 {
+		l.curPos = tokens[k].End
+		l.asi.lastType, l.asi.lastEnd = tokens[k].Type, tokens[k].End
+	}
+
+	var errs ErrorList
+	relexed := make([]token.Token, 0)
+	oldEnd := len(tokens)
+	var lines, columns int // how far lines moved, and columns on the line of tokens[oldEnd]
+	for next := start; ; {
+		tok, err := l.NextToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var lerr Error
+			if !l.recover || !errors.As(err, &lerr) {
+				return nil, Change{}, err
+			}
+			errs = append(errs, lerr)
+		}
+
+		// Once a token after the edit matches an old one, the rest would match too.
+		if err == nil && significant(tok) && tok.Start.Pos >= editEnd {
+			for next < len(tokens) && (tokens[next].Start.Pos < edit.End || tokens[next].Start.Pos+delta < tok.Start.Pos) {
+				next++
+			}
+			if next < len(tokens) && same(tokens[next], tok, delta) {
+				oldEnd = next
+				lines = tok.Start.Line - tokens[oldEnd].Start.Line
+				columns = tok.Start.Column - tokens[oldEnd].Start.Column
+				break
+			}
+		}
+
+		relexed = append(relexed, tok)
+	}
+
+	result := make([]token.Token, 0, start+len(relexed)+len(tokens)-oldEnd)
+	result = append(result, tokens[:start]...)
+	result = append(result, relexed...)
+	change := Change{Start: start, End: len(result), OldEnd: oldEnd}
+
+	if oldEnd < len(tokens) {
+		line := tokens[oldEnd].Start.Line
+		for _, tok := range tokens[oldEnd:] {
+			tok.Start = shift(tok.Start, line, delta, lines, columns)
+			tok.End = shift(tok.End, line, delta, lines, columns)
+			result = append(result, tok)
+		}
+	}
+
+	return result, change, errs.Err()
+}
+
+// significant reports whether tok is neither whitespace, a comment, nor an inserted token.
+func significant(tok token.Token) bool {
+	return tok.Type != token.WHITESPACE && tok.Type != token.COMMENT && tok.Start.Pos != tok.End.Pos
+}
+
+// same reports whether tok is the old token moved by delta bytes.
+func same(old, tok token.Token, delta int) bool {
+	return old.Type == tok.Type && old.Literal == tok.Literal &&
+		old.Start.Pos+delta == tok.Start.Pos && old.End.Pos+delta == tok.End.Pos
+}
+
+// shift moves pos, a position after an edit, by delta bytes and the given number of lines, and by
+// the given number of columns if it's on line.
+func shift(pos token.Position, line, delta, lines, columns int) token.Position {
+	if pos.Line == line {
+		pos.Column += columns
+	}
+	pos.Line += lines
+	pos.Pos += delta
+	return pos
+}
@@ -0,0 +1,64 @@
+package lexer
+
+import (
+	"io"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+// Semicolons configures automatic semicolon insertion for languages whose statements end at a
+// newline, such as Go. When a line's last token is one of the types in After, a token of type
+// Type is inserted after it, unless the next line starts with a token of one of the types in
+// Continue. The inserted tokens have no text, so they're zero-width and can be told apart from
+// semicolons in the input by their empty literals. A block comment spanning lines counts as a
+// newline, and so does the end of the input.
+type Semicolons struct {
+	Type     token.TokenType
+	After    map[token.TokenType]bool
+	Continue map[token.TokenType]bool // e.g. "." in languages where method chains can span lines
+}
+
+// asi is the state of automatic semicolon insertion. Only what's needed of the last token is kept,
+// so that tokens aren't copied in and out of it.
+type asi struct {
+	lastType token.TokenType // the type of the last token that wasn't whitespace or a comment
+	lastEnd  token.Position  // and where it ended
+	pending  bool            // whether next is waiting to be returned after an inserted semicolon
+	next     token.Token
+	nextErr  error
+}
+
+// insertSemicolon writes the next token to tok, or a semicolon if one belongs before it.
+func (l *Lexer) insertSemicolon(tok *token.Token) error {
+	if l.asi.pending {
+		*tok = l.asi.next
+		err := l.asi.nextErr
+		l.asi = asi{lastType: tok.Type, lastEnd: tok.End}
+		return err
+	}
+
+	// In recovery mode an error comes with the illegal token it's about, which ends the line
+	// before it like any other token.
+	err := l.nextToken(tok)
+	if err != nil && err != io.EOF && !tok.Start.IsValid() {
+		l.asi = asi{}
+		return err
+	}
+
+	if tok.Type == token.WHITESPACE || tok.Type == token.COMMENT {
+		return err
+	}
+
+	newline := l.newline
+	l.newline = false
+
+	if l.semicolons.After[l.asi.lastType] && (newline || err == io.EOF) && !l.semicolons.Continue[tok.Type] {
+		end := l.asi.lastEnd
+		l.asi = asi{pending: true, next: *tok, nextErr: err}
+		*tok = token.Token{Type: l.semicolons.Type, Start: end, End: end}
+		return nil
+	}
+
+	l.asi.lastType, l.asi.lastEnd = tok.Type, tok.End
+	return err
+}
@@ -0,0 +1,64 @@
+package lexer
+
+import (
+	"io"
+)
+
+// readSize is how many bytes are read from a stream at a time.
+const readSize = 64 * 1024
+
+// Init prepares the lexer to read tokens from r one at a time using NextToken. Only the input
+// belonging to the token being lexed is kept in memory, so r can be arbitrarily large.
+func (l *Lexer) Init(r io.Reader) {
+	l.file = nil
+	l.reset("", r)
+}
+
+// fill reads from the underlying reader until at least n bytes are buffered past the byte
+// offset pos or the reader is exhausted. The buffer doubles in size whenever it fills up, so a
+// token of any size is read in linear time.
+func (l *Lexer) fill(pos, n int) {
+	for l.reader != nil && pos+n > l.base+len(l.buf) {
+		if len(l.buf) == cap(l.buf) {
+			buf := make([]byte, len(l.buf), max(2*cap(l.buf), readSize))
+			copy(buf, l.buf)
+			l.buf = buf
+		}
+
+		m, err := l.reader.Read(l.buf[len(l.buf):cap(l.buf)])
+		l.buf = l.buf[:len(l.buf)+m]
+
+		if l.maxInputSize > 0 && l.base+len(l.buf) > l.maxInputSize {
+			l.readErr = InputSizeError{Max: l.maxInputSize}
+			l.reader = nil
+			return
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.reader = nil
+		}
+	}
+}
+
+// discard drops buffered input that's no longer reachable, which is everything before the
+// previous position. It's a no-op unless the lexer is reading from a stream. The rest of the input
+// is only moved to the front of the buffer once at least half of it can be dropped, so that each
+// byte is moved at most once on average.
+func (l *Lexer) discard() {
+	if !l.stream {
+		return
+	}
+
+	pos := l.prevPos.Pos
+	if !l.prevPos.IsValid() || pos > l.curPos.Pos {
+		pos = l.curPos.Pos
+	}
+
+	if n := pos - l.base; n > 0 && 2*n >= len(l.buf) {
+		l.buf = l.buf[:copy(l.buf, l.buf[n:])]
+		l.base = pos
+	}
+}
@@ -0,0 +1,56 @@
+package lexer
+
+import (
+	"sort"
+
+	"github.com/rdeusser/parsekit/token"
+)
+
+// trie is a prefix tree of operators, built once per mode so that LexOperator can match the
+// longest operator in one pass over the input.
+type trie struct {
+	children map[rune]*trie
+	typ      token.TokenType
+	op       string // the operator ending here, if any
+}
+
+func newTrie(operators map[string]token.TokenType) *trie {
+	root := &trie{}
+	for op, typ := range operators {
+		if op == "" {
+			continue
+		}
+
+		node := root
+		for _, r := range op {
+			child, ok := node.children[r]
+			if !ok {
+				if node.children == nil {
+					node.children = make(map[rune]*trie)
+				}
+				child = &trie{}
+				node.children[r] = child
+			}
+			node = child
+		}
+		node.typ, node.op = typ, op
+	}
+	return root
+}
+
+// operators returns the operators that start with the prefix that leads to t, in order.
+func (t *trie) operators() []string {
+	ops := make([]string, 0)
+	var walk func(t *trie)
+	walk = func(t *trie) {
+		if t.op != "" {
+			ops = append(ops, t.op)
+		}
+		for _, child := range t.children {
+			walk(child)
+		}
+	}
+	walk(t)
+	sort.Strings(ops)
+	return ops
+}
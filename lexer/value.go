@@ -0,0 +1,135 @@
+package lexer
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// floatPrec is the precision in bits of the *big.Float values of FLOAT tokens.
+const floatPrec = 256
+
+// escape lexes the escape sequence starting with the backslash at the current position. It
+// returns the rune the sequence stands for or, for \x and octal escapes, the byte, and whether it's
+// a byte. An invalid sequence is reported as an error, leaving the lexer at the first character
+// that isn't part of it.
+func (l *Lexer) escape() (r rune, isByte bool, err error) {
+	start := l.curPos
+	ch := l.Next()
+
+	n, base := 0, 0
+	switch ch {
+	case 'a':
+		r = '\a'
+	case 'b':
+		r = '\b'
+	case 'f':
+		r = '\f'
+	case 'n':
+		r = '\n'
+	case 'r':
+		r = '\r'
+	case 't':
+		r = '\t'
+	case 'v':
+		r = '\v'
+	case '\\', '\'', '"':
+		r = ch
+	case 'x':
+		n, base, isByte = 2, 16, true
+	case 'u':
+		n, base = 4, 16
+	case 'U':
+		n, base = 8, 16
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, base, isByte = 3, 8, true
+	default:
+		if !IsEOF(ch) && !IsNewline(ch) {
+			_ = l.Next()
+		}
+		return 0, false, Error{Lexer: l, Msg: "unknown escape sequence", Pos: start, End: l.curPos}
+	}
+
+	if n == 0 {
+		_ = l.Next()
+		return r, false, nil
+	}
+
+	if base == 16 {
+		ch = l.Next()
+	}
+
+	for i := 0; i < n; i++ {
+		if !isDigit(ch, base) {
+			if IsEOF(ch) {
+				return 0, false, Error{Lexer: l, Msg: "escape sequence not terminated", Pos: start, End: l.curPos}
+			}
+			return 0, false, Error{Lexer: l, Msg: fmt.Sprintf("invalid character %q in escape sequence", ch), Pos: l.curPos, End: l.charEnd()}
+		}
+		r = r*rune(base) + digitVal(ch)
+		ch = l.Next()
+	}
+
+	switch {
+	case base == 8 && r > 255:
+		return 0, false, Error{Lexer: l, Msg: fmt.Sprintf("octal escape value %d > 255", r), Pos: start, End: l.curPos}
+	case !isByte && !utf8.ValidRune(r):
+		return 0, false, Error{Lexer: l, Msg: "escape sequence is invalid Unicode code point", Pos: start, End: l.curPos}
+	}
+
+	return r, isByte, nil
+}
+
+// appendValue appends a rune or byte returned by escape, or a literal rune, to buf.
+func appendValue(buf []byte, r rune, isByte bool) []byte {
+	if isByte {
+		return append(buf, byte(r))
+	}
+	return utf8.AppendRune(buf, r)
+}
+
+// numberValue returns the value of a numeric literal lexed by LexNumber, without its suffix, given
+// its base prefix, whether it's a float, and the digit separator. A legacy octal prefix is ignored
+// if decimal is set.
+func numberValue(lit string, prefix rune, float, decimal bool, sep rune) any {
+	if sep != 0 {
+		lit = strings.ReplaceAll(lit, string(sep), "")
+	}
+
+	if float {
+		f, _, err := big.ParseFloat(lit, 0, floatPrec, big.ToNearestEven)
+		if err != nil {
+			return nil
+		}
+		return f
+	}
+
+	base := 10
+	switch prefix {
+	case 'x':
+		base, lit = 16, lit[2:]
+	case 'o':
+		base, lit = 8, lit[2:]
+	case 'b':
+		base, lit = 2, lit[2:]
+	case '0':
+		if !decimal {
+			base = 8
+		}
+	}
+
+	i, ok := new(big.Int).SetString(lit, base)
+	if !ok {
+		return nil
+	}
+	return i
+}
+
+// digitVal returns the value of a hexadecimal digit.
+func digitVal(ch rune) rune {
+	if '0' <= ch && ch <= '9' {
+		return ch - '0'
+	}
+	return lower(ch) - 'a' + 10
+}
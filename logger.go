@@ -13,3 +13,13 @@ type Logger interface {
 	Error(msg string, args ...any)
 	Info(msg string, args ...any)
 }
+
+// DebugEnabled reports whether logger writes debug messages. A logger can say it doesn't by
+// implementing DebugEnabled() bool, and is assumed to otherwise. Lexers and parsers check it before
+// logging on their hot paths, so that a discarded message doesn't cost anything to build.
+func DebugEnabled(logger Logger) bool {
+	if l, ok := logger.(interface{ DebugEnabled() bool }); ok {
+		return l.DebugEnabled()
+	}
+	return true
+}
@@ -25,7 +25,7 @@ func ParsePackage(p *Parser, tok token.Token) (ast.Node, error) {
 		Token: tok.Start,
 	}
 
-	name, err := ParseIdentifier(p, p.Next())
+	name, err := p.Run(ParseIdentifier, p.Next())
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +37,7 @@ func ParsePackage(p *Parser, tok token.Token) (ast.Node, error) {
 
 func ParseStruct(p *Parser, tok token.Token) (ast.Node, error) {
 	if tok.Type != token.STRUCT {
-		return nil, fmt.Errorf("expected struct, got %s", tok)
+		return nil, fmt.Errorf("expected struct, got %s", tok.Format(p.Types()))
 	}
 
 	node := &ast.Struct{
@@ -50,7 +50,7 @@ func ParseStruct(p *Parser, tok token.Token) (ast.Node, error) {
 		node.Public = true
 	}
 
-	name, err := ParseIdentifier(p, tok)
+	name, err := p.Run(ParseIdentifier, tok)
 	if err != nil {
 		return nil, err
 	}
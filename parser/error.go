@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/rdeusser/parsekit/diagnostic"
 	"github.com/rdeusser/parsekit/token"
 )
 
@@ -23,5 +24,24 @@ func (e Error) Error() string {
 	if e.Msg == "" {
 		return "Msg cannot be empty"
 	}
-	return fmt.Sprintf("%s at %s", e.Msg, e.CurToken)
+	return fmt.Sprintf("%s at %s", e.Msg, e.CurToken.Format(e.Parser.Types()))
+}
+
+// Diagnostic returns a diagnostic that underlines the current token when rendered against the
+// input.
+func (e Error) Diagnostic() diagnostic.Diagnostic {
+	return diagnostic.Diagnostic{
+		Msg:     e.Msg,
+		Primary: diagnostic.Label{Start: e.CurToken.Start, End: e.CurToken.End},
+	}
+}
+
+// DepthError is returned when actions nest deeper than the limit set by WithMaxDepth.
+type DepthError struct {
+	Max   int
+	Token token.Token // the token of the action that would have gone over the limit
+}
+
+func (e DepthError) Error() string {
+	return fmt.Sprintf("nesting depth exceeds the limit of %d at %s", e.Max, e.Token.Start)
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -17,6 +18,12 @@ type Parser struct {
 	pos    int
 	tokens []token.Token
 	logger parsekit.Logger
+	debug  bool            // whether logger writes debug messages
+	types  *token.Registry // names token types in errors
+
+	ctx      context.Context
+	maxDepth int
+	depth    int // how many actions are running
 }
 
 // Rule is a parser rule with a name, matcher, and an action to take if that matcher matches
@@ -42,6 +49,21 @@ func WithLogger(logger parsekit.Logger) Option {
 	}
 }
 
+// WithMaxDepth limits how deeply actions run through Run can nest. Nesting deeper fails with a
+// DepthError. The default is zero, meaning there's no limit.
+func WithMaxDepth(max int) Option {
+	return func(p *Parser) {
+		p.maxDepth = max
+	}
+}
+
+// WithTypes sets the registry that names token types in errors. The default is the lexer's.
+func WithTypes(types *token.Registry) Option {
+	return func(p *Parser) {
+		p.types = types
+	}
+}
+
 // New constructs a new Parser.
 func New(l *lexer.Lexer, config Config, options ...Option) *Parser {
 	parser := &Parser{
@@ -49,21 +71,38 @@ func New(l *lexer.Lexer, config Config, options ...Option) *Parser {
 		config: config,
 		tokens: make([]token.Token, 0),
 		logger: parsekit.DefaultLogger,
+		types:  l.Types(),
+		ctx:    context.Background(),
 	}
 
 	for _, option := range options {
 		option(parser)
 	}
+	parser.debug = parsekit.DebugEnabled(parser.logger)
 
 	return parser
 }
 
-func (p *Parser) Parse(input string) (file *ast.File, err error) {
+// Types returns the registry that names token types in errors.
+func (p *Parser) Types() *token.Registry {
+	return p.types
+}
+
+func (p *Parser) Parse(input string) (*ast.File, error) {
+	return p.ParseContext(context.Background(), input)
+}
+
+// ParseContext is like Parse, but stops with ctx's error if ctx is done before parsing finishes.
+// Lexing is stopped too, and actions that run other actions through Run stop as soon as they do.
+func (p *Parser) ParseContext(ctx context.Context, input string) (file *ast.File, err error) {
+	p.ctx, p.depth = ctx, 0
+	defer func() { p.ctx = context.Background() }()
+
 	file = &ast.File{
 		Nodes: make([]ast.Node, 0),
 	}
 
-	p.tokens, err = p.l.Lex(input)
+	p.tokens, err = p.l.LexContext(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("parser error: %w", err)
 	}
@@ -72,12 +111,16 @@ func (p *Parser) Parse(input string) (file *ast.File, err error) {
 		curToken := p.tokens[p.pos]
 		matched := false
 		for _, rule := range p.config.Rules {
-			p.logger.Debug("Attempting to match %q with token %q", rule.Name, curToken)
+			if p.debug {
+				p.logger.Debug("Attempting to match %q with token %s", rule.Name, curToken.Format(p.types))
+			}
 
 			if rule.Match(curToken) {
-				p.logger.Debug("Running action %q", rule.Name)
+				if p.debug {
+					p.logger.Debug("Running action %q", rule.Name)
+				}
 
-				node, err := rule.Action(p, curToken)
+				node, err := p.Run(rule.Action, curToken)
 				var perr Error
 				if errors.As(err, &perr) {
 					if perr.GotoNextRule {
@@ -101,8 +144,7 @@ func (p *Parser) Parse(input string) (file *ast.File, err error) {
 		}
 
 		if !matched {
-			// TODO(rdeusser): add output with line numbers and an up arrow at position.
-			return nil, fmt.Errorf("parser error: no rule to handle token %q", input[curToken.Start.Pos:curToken.End.Pos])
+			return nil, Error{Parser: p, CurToken: curToken, Msg: fmt.Sprintf("no rule to handle token %q", curToken.Literal)}
 		}
 
 		p.Next()
@@ -111,6 +153,26 @@ func (p *Parser) Parse(input string) (file *ast.File, err error) {
 	return file, nil
 }
 
+// Run runs action for tok one level deeper than the action calling it. Actions should run other
+// actions through Run rather than calling them directly, so that the depth they nest to is limited
+// and parsing stops when it's canceled.
+func (p *Parser) Run(action Action, tok token.Token) (ast.Node, error) {
+	select {
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	default:
+	}
+
+	if p.maxDepth > 0 && p.depth >= p.maxDepth {
+		return nil, DepthError{Max: p.maxDepth, Token: tok}
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+
+	return action(p, tok)
+}
+
 func (p *Parser) Lookahead(n int) []token.Token {
 	if p.pos+n >= len(p.tokens) {
 		return nil
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"testing"
 
 	"github.com/hexops/autogold/v2"
@@ -101,3 +102,33 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestLimits(t *testing.T) {
+	// nest parses a run of identifiers as identifiers nested in each other.
+	var nest Action
+	nest = func(p *Parser, tok token.Token) (ast.Node, error) {
+		if next := p.Lookahead(2); len(next) == 2 && IsIdentifier(next[1]) {
+			return p.Run(nest, p.Next())
+		}
+		return ParseIdentifier(p, tok)
+	}
+	config := Config{
+		Rules: []Rule{
+			{Name: "Nest", Match: IsIdentifier, Action: nest},
+		},
+	}
+
+	_, err := New(lexer.New(lexer.DefaultConfig), config, WithMaxDepth(3)).Parse("a b c d")
+	assert.NoError(t, err)
+
+	_, err = New(lexer.New(lexer.DefaultConfig), config, WithMaxDepth(2)).Parse("a b c d")
+	var derr DepthError
+	if assert.ErrorAs(t, err, &derr) {
+		assert.Equal(t, "nesting depth exceeds the limit of 2 at 1:5", derr.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = New(lexer.New(lexer.DefaultConfig), config).ParseContext(ctx, "a b c d")
+	assert.ErrorIs(t, err, context.Canceled)
+}
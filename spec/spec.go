@@ -0,0 +1,326 @@
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rdeusser/parsekit/lexer"
+	"github.com/rdeusser/parsekit/token"
+)
+
+// Format is the format of a spec file.
+type Format int
+
+const (
+	YAML Format = iota
+	JSON
+)
+
+// Spec is a declarative description of a lexer, e.g.:
+//
+//	name: calc
+//	skip_whitespace: true
+//	comments:
+//	  line: ["#"]
+//	rules:
+//	  - action: LexIdentifier
+//	  - name: Hex
+//	    pattern: 0[xX][0-9a-fA-F]+
+//	    type: HEX
+//	  - action: LexNumber
+//	  - action: LexOperator
+//	operators:
+//	  "+": ADD
+//	  "-": SUB
+//	keywords: [let, print]
+//	numbers:
+//	  hex: true
+//	  float: true
+//	  separator: _
+type Spec struct {
+	Name           string            `yaml:"name" json:"name"`
+	SkipWhitespace bool              `yaml:"skip_whitespace" json:"skip_whitespace"`
+	SkipComments   bool              `yaml:"skip_comments" json:"skip_comments"`
+	Comments       Comments          `yaml:"comments" json:"comments"`
+	Rules          []Rule            `yaml:"rules" json:"rules"`
+	Operators      map[string]string `yaml:"operators" json:"operators"` // operator → type name
+	Keywords       []string          `yaml:"keywords" json:"keywords"`   // type names are the keywords in upper case
+	Numbers        Numbers           `yaml:"numbers" json:"numbers"`
+}
+
+// Numbers describes the numeric literals that LexNumber accepts. See lexer.NumberConfig.
+type Numbers struct {
+	Hex         bool              `yaml:"hex" json:"hex"`
+	Octal       bool              `yaml:"octal" json:"octal"`
+	Binary      bool              `yaml:"binary" json:"binary"`
+	LegacyOctal bool              `yaml:"legacy_octal" json:"legacy_octal"`
+	Float       bool              `yaml:"float" json:"float"`
+	Exponent    bool              `yaml:"exponent" json:"exponent"`
+	HexFloat    bool              `yaml:"hex_float" json:"hex_float"`
+	Separator   string            `yaml:"separator" json:"separator"`
+	Suffixes    map[string]string `yaml:"suffixes" json:"suffixes"` // suffix → type name, or "" to keep the type
+}
+
+// Comments describes the comment syntax of a language.
+type Comments struct {
+	Line  []string       `yaml:"line" json:"line"`
+	Block []BlockComment `yaml:"block" json:"block"`
+}
+
+// BlockComment describes the delimiters of a block comment.
+type BlockComment struct {
+	Start  string `yaml:"start" json:"start"`
+	End    string `yaml:"end" json:"end"`
+	Nested bool   `yaml:"nested" json:"nested"`
+}
+
+// Rule is either a built-in action, named by Action, or a regular expression, given by Pattern,
+// that produces tokens of the type named by Type.
+type Rule struct {
+	Name     string `yaml:"name" json:"name"`
+	Action   string `yaml:"action" json:"action"`
+	Pattern  string `yaml:"pattern" json:"pattern"`
+	Type     string `yaml:"type" json:"type"`
+	Priority int    `yaml:"priority" json:"priority"` // breaks ties when lexing with lexer.WithLongestMatch
+}
+
+// Language is a lexer config built from a spec along with the token types allocated for it.
+type Language struct {
+	Name   string
+	Config lexer.Config
+	Types  map[string]token.TokenType // type name → type
+	Names  *token.Registry            // names of the allocated types, falling back on token.Types
+}
+
+// builtinTypes are the token types that specs can refer to without allocating new ones.
+var builtinTypes = map[string]token.TokenType{
+	"IDENT":      token.IDENT,
+	"STRING":     token.STRING,
+	"CHAR":       token.CHAR,
+	"NUMBER":     token.NUMBER,
+	"FLOAT":      token.FLOAT,
+	"IMAG":       token.IMAG,
+	"COMMENT":    token.COMMENT,
+	"WHITESPACE": token.WHITESPACE,
+}
+
+// extensions maps the file extensions Load understands to their formats.
+var extensions = map[string]Format{
+	".yaml": YAML,
+	".yml":  YAML,
+	".json": JSON,
+}
+
+// Load reads and builds the spec at path. The format is chosen by the file extension: .yaml or .yml
+// for YAML and .json for JSON. Any other extension is an error.
+func Load(path string) (*Language, error) {
+	format, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported format %q", path, filepath.Ext(path))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lang, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return lang, nil
+}
+
+// Parse parses and builds a spec.
+func Parse(data []byte, format Format) (*Language, error) {
+	var spec Spec
+
+	var err error
+	switch format {
+	case JSON:
+		err = json.Unmarshal(data, &spec)
+	case YAML:
+		err = yaml.Unmarshal(data, &spec)
+	default:
+		err = fmt.Errorf("unknown spec format %d", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return spec.Build()
+}
+
+// Build builds a lexer config from the spec. Token types other than the built-in literal types
+// are allocated in order of their names, starting at token.LiteralStart for rule types,
+// token.OperatorStart for operators, and token.KeywordStart for keywords.
+func (s Spec) Build() (*Language, error) {
+	lang := &Language{
+		Name: s.Name,
+		Config: lexer.Config{
+			SkipWhitespace: s.SkipWhitespace,
+			SkipComments:   s.SkipComments,
+			LineComments:   s.Comments.Line,
+			Operators:      make(map[string]token.TokenType, len(s.Operators)),
+			Keywords:       make(map[string]token.TokenType, len(s.Keywords)),
+		},
+		Types: make(map[string]token.TokenType),
+		Names: token.NewRegistry(token.Types),
+	}
+
+	numbers, err := s.Numbers.config()
+	if err != nil {
+		return nil, err
+	}
+	lang.Config.Numbers = numbers
+
+	for _, block := range s.Comments.Block {
+		if block.Start == "" || block.End == "" {
+			return nil, fmt.Errorf("block comments need both a start and an end delimiter")
+		}
+		lang.Config.BlockComments = append(lang.Config.BlockComments, lexer.BlockComment{
+			Start:  block.Start,
+			End:    block.End,
+			Nested: block.Nested,
+		})
+	}
+
+	ruleTypes := make([]string, 0, len(s.Rules)+len(s.Numbers.Suffixes))
+	for _, rule := range s.Rules {
+		if rule.Type != "" {
+			ruleTypes = append(ruleTypes, rule.Type)
+		}
+	}
+	for _, name := range s.Numbers.Suffixes {
+		if name != "" {
+			ruleTypes = append(ruleTypes, name)
+		}
+	}
+	if err := lang.allocate(ruleTypes, token.LiteralStart); err != nil {
+		return nil, err
+	}
+
+	opTypes := make([]string, 0, len(s.Operators))
+	for _, name := range s.Operators {
+		opTypes = append(opTypes, name)
+	}
+	if err := lang.allocate(opTypes, token.OperatorStart); err != nil {
+		return nil, err
+	}
+
+	kwTypes := make([]string, 0, len(s.Keywords))
+	for _, kw := range s.Keywords {
+		kwTypes = append(kwTypes, strings.ToUpper(kw))
+	}
+	if err := lang.allocate(kwTypes, token.KeywordStart); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range s.Rules {
+		switch {
+		case rule.Action != "" && rule.Pattern != "":
+			return nil, fmt.Errorf("rule %d: can't have both an action and a pattern", i)
+		case rule.Action != "":
+			builtin, ok := lexer.Builtins[rule.Action]
+			if !ok {
+				return nil, fmt.Errorf("rule %d: unknown action %q", i, rule.Action)
+			}
+			if rule.Name != "" {
+				builtin.Name = rule.Name
+			}
+			builtin.Priority = rule.Priority
+			lang.Config.Rules = append(lang.Config.Rules, builtin)
+		case rule.Pattern != "":
+			if rule.Type == "" {
+				return nil, fmt.Errorf("rule %d: pattern rules need a type", i)
+			}
+			name := rule.Name
+			if name == "" {
+				name = rule.Type
+			}
+			r, err := lexer.RegexpRule(name, rule.Pattern, lang.Types[rule.Type])
+			if err != nil {
+				return nil, err
+			}
+			r.Priority = rule.Priority
+			lang.Config.Rules = append(lang.Config.Rules, r)
+		default:
+			return nil, fmt.Errorf("rule %d: needs either an action or a pattern", i)
+		}
+	}
+
+	for op, name := range s.Operators {
+		lang.Config.Operators[op] = lang.Types[name]
+	}
+
+	for suffix, name := range s.Numbers.Suffixes {
+		lang.Config.Numbers.Suffixes[suffix] = lang.Types[name]
+	}
+
+	for _, kw := range s.Keywords {
+		lang.Config.Keywords[kw] = lang.Types[strings.ToUpper(kw)]
+	}
+
+	return lang, nil
+}
+
+// config builds the number config, except for the types of suffixes, which aren't allocated yet.
+func (n Numbers) config() (lexer.NumberConfig, error) {
+	config := lexer.NumberConfig{
+		Hex:         n.Hex,
+		Octal:       n.Octal,
+		Binary:      n.Binary,
+		LegacyOctal: n.LegacyOctal,
+		Float:       n.Float,
+		Exponent:    n.Exponent,
+		HexFloat:    n.HexFloat,
+	}
+
+	switch {
+	case len(n.Separator) == 1 && n.Separator[0] < utf8.RuneSelf:
+		config.Separator = rune(n.Separator[0])
+	case n.Separator != "":
+		return config, fmt.Errorf("number separator %q must be a single ASCII character", n.Separator)
+	}
+
+	if len(n.Suffixes) > 0 {
+		config.Suffixes = make(map[string]token.TokenType, len(n.Suffixes))
+	}
+
+	return config, nil
+}
+
+// allocate allocates token types for names that don't have one yet, counting up from start.
+func (lang *Language) allocate(names []string, start token.TokenType) error {
+	sort.Strings(names)
+
+	next := start
+
+	for _, name := range names {
+		if _, ok := lang.Types[name]; ok {
+			continue
+		}
+		if typ, ok := builtinTypes[name]; ok {
+			lang.Types[name] = typ
+			continue
+		}
+		if next >= start+1000 {
+			return fmt.Errorf("too many token types starting at %d", start)
+		}
+		lang.Types[name] = next
+		if err := lang.Names.Register(next, token.Info{Name: name}); err != nil {
+			return err
+		}
+		next++
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdeusser/parsekit/lexer"
+	"github.com/rdeusser/parsekit/token"
+)
+
+func TestParse(t *testing.T) {
+	const yamlSpec = `
+name: calc
+skip_whitespace: true
+comments:
+  line: ["#"]
+  block:
+    - start: "(*"
+      end: "*)"
+      nested: true
+rules:
+  - action: LexIdentifier
+  - name: Hex
+    pattern: 0[xX][0-9a-fA-F]+
+    type: HEX
+  - action: LexNumber
+  - action: LexOperator
+operators:
+  "+": ADD
+  "*": MUL
+  "=": ASSIGN
+keywords: [let, print]
+numbers:
+  float: true
+  separator: _
+  suffixes:
+    u: UNSIGNED
+`
+
+	const jsonSpec = `{
+  "name": "calc",
+  "skip_whitespace": true,
+  "comments": {"line": ["#"], "block": [{"start": "(*", "end": "*)", "nested": true}]},
+  "rules": [
+    {"action": "LexIdentifier"},
+    {"name": "Hex", "pattern": "0[xX][0-9a-fA-F]+", "type": "HEX"},
+    {"action": "LexNumber"},
+    {"action": "LexOperator"}
+  ],
+  "operators": {"+": "ADD", "*": "MUL", "=": "ASSIGN"},
+  "keywords": ["let", "print"],
+  "numbers": {"float": true, "separator": "_", "suffixes": {"u": "UNSIGNED"}}
+}`
+
+	tests := map[string]struct {
+		data   string
+		format Format
+	}{
+		"yaml": {yamlSpec, YAML},
+		"json": {jsonSpec, JSON},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			lang, err := Parse([]byte(tt.data), tt.format)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, "calc", lang.Name)
+			assert.Equal(t, map[string]token.TokenType{
+				"HEX":      token.LiteralStart,
+				"UNSIGNED": token.LiteralStart + 1,
+				"ADD":      token.OperatorStart,
+				"ASSIGN":   token.OperatorStart + 1,
+				"MUL":      token.OperatorStart + 2,
+				"LET":      token.KeywordStart,
+				"PRINT":    token.KeywordStart + 1,
+			}, lang.Types)
+			assert.Equal(t, "HEX", lang.Names.Name(token.LiteralStart))
+			assert.Equal(t, "IDENT", lang.Names.Name(token.IDENT))
+
+			tokens, err := lexer.New(lang.Config).Lex("let x = 0xFF * 2.5 + 1_000u (* a (* nested *) comment *) # done")
+			assert.NoError(t, err)
+
+			types := make([]token.TokenType, 0, len(tokens))
+			for _, tok := range tokens {
+				types = append(types, tok.Type)
+			}
+			assert.Equal(t, []token.TokenType{
+				lang.Types["LET"],
+				token.IDENT,
+				lang.Types["ASSIGN"],
+				lang.Types["HEX"],
+				lang.Types["MUL"],
+				token.FLOAT,
+				lang.Types["ADD"],
+				lang.Types["UNSIGNED"],
+				token.COMMENT,
+				token.COMMENT,
+			}, types)
+		})
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := map[string]Spec{
+		"unknown action":        {Rules: []Rule{{Action: "LexNothing"}}},
+		"action and pattern":    {Rules: []Rule{{Action: "LexIdentifier", Pattern: "[a-z]+", Type: "IDENT"}}},
+		"pattern without type":  {Rules: []Rule{{Pattern: "[a-z]+"}}},
+		"invalid pattern":       {Rules: []Rule{{Pattern: "[a-z", Type: "IDENT"}}},
+		"empty rule":            {Rules: []Rule{{Name: "Nothing"}}},
+		"half a block comment":  {Comments: Comments{Block: []BlockComment{{Start: "/*"}}}},
+		"long number separator": {Numbers: Numbers{Separator: "__"}},
+	}
+
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := spec.Build()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"calc.yaml", "calc.yml", "calc.YAML", "calc.json"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(`{"name": "calc", "rules": [{"action": "LexIdentifier"}]}`), 0o644))
+
+		lang, err := Load(path)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, "calc", lang.Name)
+		}
+	}
+
+	for _, name := range []string{"calc.toml", "calc"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte("name: calc\n"), 0o644))
+
+		_, err := Load(path)
+		assert.ErrorContains(t, err, "unsupported format", name)
+	}
+}
@@ -0,0 +1,113 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding is a way of counting columns. Lexers count them in runes by default, but editors and
+// language servers may count them differently: the Language Server Protocol counts UTF-16 code
+// units unless the client and server agree on something else.
+type Encoding int
+
+const (
+	Runes Encoding = iota // Unicode code points, or UTF-32 code units
+	UTF8                  // bytes
+	UTF16                 // UTF-16 code units, so characters outside the BMP take up two columns
+)
+
+// String returns the name of the encoding as the Language Server Protocol spells it.
+func (e Encoding) String() string {
+	switch e {
+	case Runes:
+		return "utf-32"
+	case UTF8:
+		return "utf-8"
+	case UTF16:
+		return "utf-16"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// ParseEncoding returns the encoding with the name that the Language Server Protocol gives it.
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-32":
+		return Runes, nil
+	case "utf-8":
+		return UTF8, nil
+	case "utf-16":
+		return UTF16, nil
+	}
+	return 0, fmt.Errorf("unknown position encoding %q", name)
+}
+
+// Width returns the number of columns the character r, encoded in size bytes of UTF-8, takes up.
+// An invalid encoding decodes as utf8.RuneError with a size of 1 and takes up one column.
+func (e Encoding) Width(r rune, size int) int {
+	switch {
+	case e == UTF8:
+		return size
+	case e == UTF16 && r >= 0x10000:
+		return 2
+	}
+	return 1
+}
+
+// Columns returns the number of columns s takes up.
+func (e Encoding) Columns(s string) int {
+	n := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		n += e.Width(r, size)
+		s = s[size:]
+	}
+	return n
+}
+
+// Convert returns pos, a position in src, with its column counted in e. Since the column is worked
+// out from the byte offset, it doesn't matter how pos counted it.
+func (e Encoding) Convert(src string, pos Position) Position {
+	if pos.Pos < 0 || pos.Pos > len(src) {
+		return pos
+	}
+	start := strings.LastIndexByte(src[:pos.Pos], '\n') + 1
+	pos.Column = e.Columns(src[start:pos.Pos]) + 1
+	return pos
+}
+
+// Position returns the position in src of the line and column, both counted from 1, with the
+// column counted in e. The column of the returned position is counted in runes. It's an error if
+// there's no such position, or if it's in the middle of a character. The end of a line, where its
+// newline is, counts as being on the line.
+func (e Encoding) Position(src string, line, column int) (Position, error) {
+	if line < 1 || column < 1 {
+		return Position{}, fmt.Errorf("invalid position %d:%d", line, column)
+	}
+
+	start := 0
+	for i := 1; i < line; i++ {
+		n := strings.IndexByte(src[start:], '\n')
+		if n < 0 {
+			return Position{}, fmt.Errorf("line %d is past the end of the input", line)
+		}
+		start += n + 1
+	}
+
+	pos := Position{Pos: start, Line: line, Column: 1}
+	for col := 1; col < column; {
+		r, size := utf8.DecodeRuneInString(src[pos.Pos:])
+		if size == 0 || r == '\n' {
+			return Position{}, fmt.Errorf("column %d is past the end of line %d", column, line)
+		}
+		col += e.Width(r, size)
+		if col > column {
+			return Position{}, fmt.Errorf("column %d of line %d is in the middle of a character", column, line)
+		}
+		pos.Pos += size
+		pos.Column++
+	}
+
+	return pos, nil
+}
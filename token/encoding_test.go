@@ -0,0 +1,43 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding(t *testing.T) {
+	src := "a\né🎉x\n"
+	x := Position{Pos: 8, Line: 2, Column: 3}
+
+	assert.Equal(t, Position{Pos: 8, Line: 2, Column: 3}, Runes.Convert(src, x))
+	assert.Equal(t, Position{Pos: 8, Line: 2, Column: 7}, UTF8.Convert(src, x))
+	assert.Equal(t, Position{Pos: 8, Line: 2, Column: 4}, UTF16.Convert(src, x))
+
+	for _, enc := range []Encoding{Runes, UTF8, UTF16} {
+		converted := enc.Convert(src, x)
+		pos, err := enc.Position(src, converted.Line, converted.Column)
+		assert.NoError(t, err, enc.String())
+		assert.Equal(t, x, pos, enc.String())
+
+		parsed, err := ParseEncoding(enc.String())
+		assert.NoError(t, err)
+		assert.Equal(t, enc, parsed)
+	}
+
+	_, err := UTF16.Position(src, 2, 3)
+	assert.EqualError(t, err, "column 3 of line 2 is in the middle of a character")
+
+	_, err = UTF8.Position(src, 1, 3)
+	assert.EqualError(t, err, "column 3 is past the end of line 1")
+
+	_, err = Runes.Position(src, 4, 1)
+	assert.EqualError(t, err, "line 4 is past the end of the input")
+
+	pos, err := Runes.Position(src, 3, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Position{Pos: 10, Line: 3, Column: 1}, pos)
+
+	_, err = ParseEncoding("utf-7")
+	assert.Error(t, err)
+}
@@ -0,0 +1,239 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// Pos is a compact position in a FileSet: the base of the file it's in plus a byte offset into
+// the file. A Pos can be turned into a Position with FileSet.Position, or File.Position if the
+// file is known.
+type Pos int
+
+// NoPos is the zero Pos, which isn't in any file.
+const NoPos Pos = 0
+
+// IsValid reports whether p isn't NoPos.
+func (p Pos) IsValid() bool { return p != NoPos }
+
+// File is a file in a FileSet. It keeps a table of the offsets at which its lines start, and one
+// of the characters that take up fewer columns than bytes, so that offsets can be turned into
+// lines and columns without going back to the content. Without the second table columns are
+// counted in bytes.
+type File struct {
+	name string
+	base int
+	size int
+
+	mu    sync.RWMutex
+	lines []int  // offsets of the start of each line; lines[0] is always 0
+	chars []char // characters narrower than their encoding, sorted by offset
+}
+
+// char is a character that takes up fewer columns than it has bytes.
+type char struct {
+	offset int // offset of the byte after the character
+	extra  int // bytes not counted as columns, in this character and all those before it
+}
+
+// Name returns the name the file was added with.
+func (f *File) Name() string { return f.name }
+
+// Base returns the Pos of the file's first byte.
+func (f *File) Base() int { return f.base }
+
+// Size returns the size of the file in bytes.
+func (f *File) Size() int { return f.size }
+
+// LineCount returns the number of lines in the file's line table.
+func (f *File) LineCount() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.lines)
+}
+
+// AddLine adds a line starting at offset, which must be after the start of the last line and no
+// further than the end of the file. Offsets that aren't are ignored.
+func (f *File) AddLine(offset int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if offset > f.lines[len(f.lines)-1] && offset <= f.size {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// AddChar notes that the character at offset is size bytes long but takes up width columns, which
+// must be fewer. It must be after the last character added. Characters that aren't are ignored, as
+// are those whose width is their size, which is what it's taken to be by default.
+func (f *File) AddChar(offset, size, width int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.addChar(offset, size, width)
+}
+
+func (f *File) addChar(offset, size, width int) {
+	extra := 0
+	if n := len(f.chars); n > 0 {
+		if offset < f.chars[n-1].offset {
+			return
+		}
+		extra = f.chars[n-1].extra
+	}
+	if width < size && offset+size <= f.size {
+		f.chars = append(f.chars, char{offset: offset + size, extra: extra + size - width})
+	}
+}
+
+// SetLinesForContent builds the file's tables from its content, with columns counted in e. A line
+// starts after every '\n', so "\r\n" ends a line too. A lexer builds the same tables as it lexes
+// the file, so this is only needed for files that haven't been lexed.
+func (f *File) SetLinesForContent(content string, e Encoding) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lines = make([]int, 1, len(content)/32+1)
+	f.chars = f.chars[:0]
+	for i := 0; i < len(content); {
+		if content[i] < utf8.RuneSelf {
+			if content[i] == '\n' {
+				f.lines = append(f.lines, i+1)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(content[i:])
+		f.addChar(i, size, e.Width(r, size))
+		i += size
+	}
+}
+
+// Pos returns the Pos of the byte offset in the file. It panics if offset is past the end of the
+// file.
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > f.size {
+		panic(fmt.Sprintf("invalid offset %d, file %s has size %d", offset, f.name, f.size))
+	}
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset in the file of p. It panics if p isn't in the file.
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size {
+		panic(fmt.Sprintf("invalid Pos %d, file %s covers %d to %d", p, f.name, f.base, f.base+f.size))
+	}
+	return int(p) - f.base
+}
+
+// Position returns the Position of p, which must be in the file.
+func (f *File) Position(p Pos) Position {
+	return f.position(f.Offset(p))
+}
+
+// position returns the Position of the byte offset in the file.
+func (f *File) position(offset int) Position {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	column := offset - f.lines[i] + 1 - f.extra(offset) + f.extra(f.lines[i])
+
+	return Position{Pos: offset, Line: i + 1, Column: column}
+}
+
+// extra returns the number of bytes before offset that aren't counted as columns.
+func (f *File) extra(offset int) int {
+	i := sort.Search(len(f.chars), func(i int) bool { return f.chars[i].offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return f.chars[i-1].extra
+}
+
+// FileSet is a set of files, each covering its own range of Pos values, so that a Pos identifies
+// both a file and an offset in it. Tokens and errors from different files can then be told apart.
+type FileSet struct {
+	mu    sync.RWMutex
+	base  int
+	files []*File // sorted by base
+	last  *File   // the file of the last lookup
+}
+
+// NewFileSet creates an empty file set.
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// Base returns the smallest base a file can be added with.
+func (s *FileSet) Base() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.base
+}
+
+// AddFile adds a file of size bytes at base, or at Base() if base is negative. It panics if base
+// is less than Base(). The Pos values of the file range from base to base+size, so the next file
+// can start at base+size+1.
+func (s *FileSet) AddFile(filename string, base, size int) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if base < 0 {
+		base = s.base
+	}
+	if base < s.base {
+		panic(fmt.Sprintf("invalid base %d, must be at least %d", base, s.base))
+	}
+	if size < 0 {
+		panic(fmt.Sprintf("invalid size %d", size))
+	}
+
+	f := &File{name: filename, base: base, size: size, lines: []int{0}}
+	s.files = append(s.files, f)
+	s.base = base + size + 1
+	s.last = f
+
+	return f
+}
+
+// File returns the file containing p, or nil if there isn't one.
+func (s *FileSet) File(p Pos) *File {
+	if p == NoPos {
+		return nil
+	}
+
+	s.mu.RLock()
+	f := s.last
+	s.mu.RUnlock()
+	if f != nil && f.base <= int(p) && int(p) <= f.base+f.size {
+		return f
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 || int(p) > s.files[i].base+s.files[i].size {
+		return nil
+	}
+	s.last = s.files[i]
+
+	return s.files[i]
+}
+
+// Position returns the Position of p, or the zero Position if p isn't in any of the files.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
+
+// Format returns p as file:line:column, or - if p isn't in any of the files.
+func (s *FileSet) Format(p Pos) string {
+	if f := s.File(p); f != nil {
+		return f.name + ":" + f.Position(p).String()
+	}
+	return "-"
+}
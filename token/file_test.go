@@ -0,0 +1,58 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSet(t *testing.T) {
+	fset := NewFileSet()
+
+	a := fset.AddFile("a.txt", -1, 12)
+	a.SetLinesForContent("héllo\r\nwor\n", Runes)
+	b := fset.AddFile("b.txt", -1, 3)
+	b.AddLine(2)
+
+	assert.Equal(t, 1, a.Base())
+	assert.Equal(t, 14, b.Base())
+	assert.Equal(t, 18, fset.Base())
+	assert.Equal(t, 3, a.LineCount())
+
+	assert.Equal(t, Position{Pos: 0, Line: 1, Column: 1}, fset.Position(a.Pos(0)))
+	assert.Equal(t, Position{Pos: 6, Line: 1, Column: 6}, fset.Position(a.Pos(6)))
+	assert.Equal(t, Position{Pos: 8, Line: 2, Column: 1}, fset.Position(a.Pos(8)))
+	assert.Equal(t, Position{Pos: 12, Line: 3, Column: 1}, fset.Position(a.Pos(12)))
+	assert.Equal(t, Position{Pos: 2, Line: 2, Column: 1}, fset.Position(b.Pos(2)))
+	assert.Equal(t, "b.txt:1:2", fset.Format(b.Pos(1)))
+	assert.Equal(t, "-", fset.Format(100))
+
+	assert.Nil(t, fset.File(NoPos))
+	assert.Nil(t, fset.File(100))
+	assert.Equal(t, Position{}, fset.Position(100))
+	assert.Equal(t, a, fset.File(a.Pos(3)))
+
+	assert.Panics(t, func() { a.Pos(13) })
+	assert.Panics(t, func() { fset.AddFile("c.txt", 1, 0) })
+
+	// Columns are counted in the encoding the tables were built with, or by the lexer with.
+	content := "🎉é x\n🎉y"
+	c := fset.AddFile("c.txt", -1, len(content))
+	for _, tt := range []struct {
+		enc    Encoding
+		column int
+	}{{Runes, 4}, {UTF8, 8}, {UTF16, 5}} {
+		c.SetLinesForContent(content, tt.enc)
+		assert.Equal(t, Position{Pos: 7, Line: 1, Column: tt.column}, c.Position(c.Pos(7)), tt.enc.String())
+		assert.Equal(t, tt.enc.Convert(content, Position{Pos: 14, Line: 2}), c.Position(c.Pos(14)), tt.enc.String())
+	}
+
+	d := fset.AddFile("d.txt", -1, len(content))
+	d.AddChar(0, 4, 2)
+	d.AddChar(4, 2, 1)
+	d.AddChar(0, 4, 2) // ignored, since it's before the last one
+	d.AddLine(9)
+	d.AddChar(9, 4, 2)
+	assert.Equal(t, Position{Pos: 7, Line: 1, Column: 5}, d.Position(d.Pos(7)))
+	assert.Equal(t, Position{Pos: 14, Line: 2, Column: 4}, d.Position(d.Pos(14)))
+}
@@ -7,9 +7,9 @@ import (
 
 // Position is the position of a token.
 type Position struct {
-	Pos    int // character position
+	Pos    int // byte offset, starting at 0
 	Line   int // line number, starting at 1
-	Column int // column number, starting at 1 (byte count)
+	Column int // column number, starting at 1 (rune count, unless counted in another Encoding)
 }
 
 // IsValid reports whether the position is valid.
@@ -0,0 +1,97 @@
+package token
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Info describes a token type.
+type Info struct {
+	Name string // e.g. "ADD"
+	Text string // the text of every token of the type, if it's always the same, e.g. "+"
+}
+
+// Registry maps token types to their names. A registry can have a parent that it falls back on,
+// so that a language can name its own types without clashing with other languages that use the
+// same values.
+type Registry struct {
+	parent *Registry
+	mu     sync.RWMutex
+	types  map[TokenType]Info
+}
+
+// Types is the registry used by TokenType.String. It has the built-in types along with the types
+// of languages that register themselves, like lang/golang.
+var Types = NewRegistry(nil)
+
+func init() {
+	for _, t := range types {
+		Types.MustRegister(t.typ, t.info)
+	}
+}
+
+// NewRegistry creates an empty registry that falls back on parent, if it isn't nil.
+func NewRegistry(parent *Registry) *Registry {
+	return &Registry{
+		parent: parent,
+		types:  make(map[TokenType]Info),
+	}
+}
+
+// Register registers a name for typ. It's an error to register a type twice, but a type can be
+// registered in a registry and its parent, in which case the registry's name wins.
+func (r *Registry) Register(typ TokenType, info Info) error {
+	if info.Name == "" {
+		return fmt.Errorf("token type %d needs a name", int(typ))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.types[typ]; ok {
+		return fmt.Errorf("token type %d is already registered as %s, can't register it as %s", int(typ), existing.Name, info.Name)
+	}
+
+	r.types[typ] = info
+
+	return nil
+}
+
+// MustRegister is like Register but panics if typ can't be registered. It's meant for registering
+// types in init functions.
+func (r *Registry) MustRegister(typ TokenType, info Info) {
+	if err := r.Register(typ, info); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the info registered for typ.
+func (r *Registry) Lookup(typ TokenType) (Info, bool) {
+	r.mu.RLock()
+	info, ok := r.types[typ]
+	r.mu.RUnlock()
+
+	if !ok && r.parent != nil {
+		return r.parent.Lookup(typ)
+	}
+
+	return info, ok
+}
+
+// Name returns the name registered for typ, or TokenType(n) if there isn't one.
+func (r *Registry) Name(typ TokenType) string {
+	if info, ok := r.Lookup(typ); ok {
+		return info.Name
+	}
+	return fmt.Sprintf("TokenType(%d)", int(typ))
+}
+
+// Register registers a name and text for typ in Types.
+func Register(typ TokenType, name, text string) error {
+	return Types.Register(typ, Info{Name: name, Text: text})
+}
+
+// String returns the name registered for t in Types.
+func (t TokenType) String() string {
+	return Types.Name(t)
+}
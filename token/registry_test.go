@@ -0,0 +1,29 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	assert.Equal(t, "ADD", ADD.String())
+	assert.Equal(t, "TokenType(999)", TokenType(999).String())
+
+	info, ok := Types.Lookup(ADD)
+	assert.True(t, ok)
+	assert.Equal(t, Info{Name: "ADD", Text: "+"}, info)
+
+	r := NewRegistry(Types)
+	assert.NoError(t, r.Register(OperatorStart, Info{Name: "PLUS", Text: "+"}))
+	assert.Error(t, r.Register(OperatorStart, Info{Name: "ADD", Text: "+"}))
+	assert.Error(t, r.Register(OperatorStart+1, Info{}))
+
+	assert.Equal(t, "PLUS", r.Name(OperatorStart))
+	assert.Equal(t, "IDENT", r.Name(IDENT))
+
+	// Types registered in a registry can shadow those of its parent.
+	assert.NoError(t, r.Register(IDENT, Info{Name: "NAME"}))
+	assert.Equal(t, "NAME", r.Name(IDENT))
+	assert.Equal(t, "IDENT", IDENT.String())
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 )
 
+//go:generate go run ../internal/gentypes -out types.go
+
 // TokenType is a type of token. Duh?
 type TokenType int
 
@@ -83,6 +85,14 @@ const (
 	WHEN    // when
 	IF      // if
 
+	// Layout tokens inserted by the lexer.
+	NEWLINE // NEWLINE
+	INDENT  // INDENT
+	DEDENT  // DEDENT
+
+	// Imaginary number literals, added after the others to keep their values.
+	IMAG // IMAG
+
 	// User-defined identifiers and basic type literals.
 	LiteralStart = 1000
 
@@ -98,12 +108,23 @@ type Token struct {
 	Start   Position
 	End     Position
 	Literal string
+
+	// Value is the decoded value of a literal: the text of a STRING or CHAR token with its quotes
+	// removed and escape sequences processed as a string, and the value of a number as a *big.Int
+	// for integers or a *big.Float for floats. It's only set by lexers that decode values.
+	Value any
 }
 
 var NoToken = Token{}
 
 func (t Token) String() string {
-	return fmt.Sprintf("Token{Type: %d, Start: %s, End: %s}", t.Type, t.Start, t.End)
+	return t.Format(Types)
+}
+
+// Format is like String, but names the token's type with r, which is how tokens of a language
+// that registers its types in its own registry should be printed.
+func (t Token) Format(r *Registry) string {
+	return fmt.Sprintf("Token{Type: %s, Start: %s, End: %s}", r.Name(t.Type), t.Start, t.End)
 }
 
 func (t Token) Pos() string {
@@ -112,3 +133,31 @@ func (t Token) Pos() string {
 	}
 	return fmt.Sprintf("%s:%s", t.Start, t.End)
 }
+
+// Span is the compact form of a token: its type and where its text is in the input, without the
+// literal, positions, or value. A Span takes up around a quarter of the memory of a Token, and the
+// rest can be resolved from the input when it's needed.
+type Span struct {
+	Type   TokenType
+	Offset int // byte offset of the start of the token
+	Len    int // length of the token in bytes
+}
+
+// End returns the byte offset of the end of the span.
+func (s Span) End() int { return s.Offset + s.Len }
+
+// Literal returns the text of the span in input.
+func (s Span) Literal(input string) string { return input[s.Offset:s.End()] }
+
+// Position returns the positions of the start and end of the span, which is in f. They're looked up
+// in the file's tables, which the lexer builds as it lexes the file, or File.SetLinesForContent
+// builds from its content.
+func (s Span) Position(f *File) (start, end Position) {
+	return f.position(s.Offset), f.position(s.End())
+}
+
+// Token returns the token that the span, which is in f with the content input, stands for.
+func (s Span) Token(f *File, input string) Token {
+	start, end := s.Position(f)
+	return Token{Type: s.Type, Start: start, End: end, Literal: s.Literal(input)}
+}
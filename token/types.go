@@ -0,0 +1,74 @@
+// Code generated by gentypes from token.go; DO NOT EDIT.
+
+package token
+
+// types are the names of the token types declared in token.go.
+var types = []struct {
+	typ  TokenType
+	info Info
+}{
+	{ILLEGAL, Info{Name: "ILLEGAL"}},
+	{EOF, Info{Name: "EOF"}},
+	{COMMENT, Info{Name: "COMMENT"}},
+	{WHITESPACE, Info{Name: "WHITESPACE"}},
+	{IDENT, Info{Name: "IDENT"}},
+	{STRING, Info{Name: "STRING"}},
+	{CHAR, Info{Name: "CHAR"}},
+	{NUMBER, Info{Name: "NUMBER"}},
+	{FLOAT, Info{Name: "FLOAT"}},
+	{ADD, Info{Name: "ADD", Text: "+"}},
+	{SUB, Info{Name: "SUB", Text: "-"}},
+	{MUL, Info{Name: "MUL", Text: "*"}},
+	{QUO, Info{Name: "QUO", Text: "/"}},
+	{REM, Info{Name: "REM", Text: "%"}},
+	{AND, Info{Name: "AND", Text: "&"}},
+	{OR, Info{Name: "OR", Text: "|"}},
+	{XOR, Info{Name: "XOR", Text: "^"}},
+	{SHL, Info{Name: "SHL", Text: "<<"}},
+	{SHR, Info{Name: "SHR", Text: ">>"}},
+	{AND_NOT, Info{Name: "AND_NOT", Text: "&^"}},
+	{ADD_ASSIGN, Info{Name: "ADD_ASSIGN", Text: "+="}},
+	{SUB_ASSIGN, Info{Name: "SUB_ASSIGN", Text: "-="}},
+	{MUL_ASSIGN, Info{Name: "MUL_ASSIGN", Text: "*="}},
+	{QUO_ASSIGN, Info{Name: "QUO_ASSIGN", Text: "/="}},
+	{REM_ASSIGN, Info{Name: "REM_ASSIGN", Text: "%="}},
+	{AND_ASSIGN, Info{Name: "AND_ASSIGN", Text: "&="}},
+	{OR_ASSIGN, Info{Name: "OR_ASSIGN", Text: "|="}},
+	{XOR_ASSIGN, Info{Name: "XOR_ASSIGN", Text: "^="}},
+	{SHL_ASSIGN, Info{Name: "SHL_ASSIGN", Text: "<<="}},
+	{SHR_ASSIGN, Info{Name: "SHR_ASSIGN", Text: ">>="}},
+	{AND_NOT_ASSIGN, Info{Name: "AND_NOT_ASSIGN", Text: "&^="}},
+	{LAND, Info{Name: "LAND", Text: "&&"}},
+	{LOR, Info{Name: "LOR", Text: "||"}},
+	{ARROW, Info{Name: "ARROW", Text: "<-"}},
+	{INC, Info{Name: "INC", Text: "++"}},
+	{DEC, Info{Name: "DEC", Text: "--"}},
+	{EQL, Info{Name: "EQL", Text: "=="}},
+	{LSS, Info{Name: "LSS", Text: "<"}},
+	{GTR, Info{Name: "GTR", Text: ">"}},
+	{ASSIGN, Info{Name: "ASSIGN", Text: "="}},
+	{NOT, Info{Name: "NOT", Text: "!"}},
+	{NEQ, Info{Name: "NEQ", Text: "!="}},
+	{LEQ, Info{Name: "LEQ", Text: "<="}},
+	{GEQ, Info{Name: "GEQ", Text: ">="}},
+	{DEFINE, Info{Name: "DEFINE", Text: ":="}},
+	{ELLIPSIS, Info{Name: "ELLIPSIS", Text: "..."}},
+	{LPAREN, Info{Name: "LPAREN", Text: "("}},
+	{LBRACK, Info{Name: "LBRACK", Text: "["}},
+	{LBRACE, Info{Name: "LBRACE", Text: "{"}},
+	{COMMA, Info{Name: "COMMA", Text: ","}},
+	{PERIOD, Info{Name: "PERIOD", Text: "."}},
+	{RPAREN, Info{Name: "RPAREN", Text: ")"}},
+	{RBRACK, Info{Name: "RBRACK", Text: "]"}},
+	{RBRACE, Info{Name: "RBRACE", Text: "}"}},
+	{SEMICOLON, Info{Name: "SEMICOLON", Text: ";"}},
+	{COLON, Info{Name: "COLON", Text: ":"}},
+	{PACKAGE, Info{Name: "PACKAGE", Text: "package"}},
+	{STRUCT, Info{Name: "STRUCT", Text: "struct"}},
+	{WHEN, Info{Name: "WHEN", Text: "when"}},
+	{IF, Info{Name: "IF", Text: "if"}},
+	{NEWLINE, Info{Name: "NEWLINE"}},
+	{INDENT, Info{Name: "INDENT"}},
+	{DEDENT, Info{Name: "DEDENT"}},
+	{IMAG, Info{Name: "IMAG"}},
+}